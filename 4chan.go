@@ -11,22 +11,90 @@ import (
 	"log"
 	"net/http"
 	"os"
-	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
+// Minimum interval between requests to the same host. The API docs ask for no
+// more than 1 request per second; the image CDN is more lenient.
+var rateLimits = map[string]time.Duration{
+	"a.4cdn.org": time.Second,
+	"i.4cdn.org": time.Second / 5,
+}
+
+var limiter = struct {
+	sync.Mutex
+	next map[string]time.Time
+}{next: map[string]time.Time{}}
+
+// http.Get, subject to rateLimits. All requests to 4chan should go through
+// this (or do).
+func get(url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return do(req)
+}
+
+func do(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+
+	limiter.Lock()
+	now := time.Now()
+	next := limiter.next[host]
+	if next.Before(now) {
+		next = now
+	}
+	limiter.next[host] = next.Add(rateLimits[host])
+	limiter.Unlock()
+
+	time.Sleep(next.Sub(now))
+	return http.DefaultClient.Do(req)
+}
+
 type Post struct {
 	Board    string // must be inherited from parent Thread/Catalog
 	Subject  string `json:"sub"` // often empty in Thread
 	Comment  string `json:"com"` // raw html
+	Name     string
+	Trip     string // may be empty
+	ID       string // poster ID; only on some boards
+	Country  string // country code; only on some boards
 	Filename string // original name at upload time
 	Ext      string // starts with "."
+	Size     int    `json:"fsize"` // in bytes
+	W        int    // image width, in pixels
+	H        int    // image height, in pixels
+	MD5      string // base64
 	Time     int    `json:"tim"`
 	Num      int    `json:"no"`
+	Created  int    `json:"time"` // unix timestamp
+	Archived int    // OP only; 1 if archived
+
+	// catalog only
+	Replies     int
+	Images      int
+	UniqueIPs   int     `json:"unique_ips"` // may be 0
+	LastReplies []*Post `json:"last_replies"`
+	bumpOrder   int     // position in catalog, as returned by the API
+
+	filter filtered // set by applyFilters
 	// LastModified int `json:"last_modified"` // may be 0
 }
 
+// Returns time of the last reply to a thread (or its creation time, if there
+// are no replies). Catalog only.
+func (p Post) lastReplyTime() int {
+	if len(p.LastReplies) == 0 {
+		return p.Created
+	}
+	return p.LastReplies[len(p.LastReplies)-1].Created
+}
+
 // Render comment as HTML, then add quoted post(s) with indentation.
 func (p Post) QuoteComment(t *Thread) string {
 	// comm := renderHTML(p.Comment)
@@ -50,6 +118,17 @@ func (p Post) QuoteComment(t *Thread) string {
 	return strings.Join(lines, "\n")
 }
 
+var quotelink = regexp.MustCompile(`&gt;&gt;(\d+)`)
+
+// Returns the numbers of all posts quoted by this post
+func (p Post) quotes() (ids []int) {
+	for _, m := range quotelink.FindAllStringSubmatch(p.Comment, -1) {
+		id, _ := strconv.Atoi(m[1])
+		ids = append(ids, id)
+	}
+	return ids
+}
+
 func (p Post) htmlComment() []string {
 	return renderHTML(p.Comment)
 }
@@ -78,47 +157,98 @@ func (p Post) imageUrl() (url string, err error) {
 	return url, nil
 }
 
-// Returns path to temp image file
-func (p Post) imagePath() (fname string, err error) {
+// Returns the original file, as uploaded
+func (p Post) original() (f remoteFile, err error) {
 	url, err := p.imageUrl()
 	if err != nil {
-		return "", err
+		return f, err
 	}
+	return remoteFile{url: url, cache: originals, size: int64(p.Size), md5: p.MD5}, nil
+}
 
-	path := filepath.Join(tmpDir, filepath.Base(url))
-	return path, nil
+// Thumbnails are always jpg, regardless of the original format
+func (p Post) thumbnail() (f remoteFile, err error) {
+	if p.Time == 0 {
+		return f, errors.New("no image")
+	}
+	url := fmt.Sprintf("https://i.4cdn.org/%s/%ds.jpg", p.Board, p.Time)
+	return remoteFile{url: url, cache: thumbnails}, nil
 }
 
-func (p Post) download() {
-	url, err := p.imageUrl()
+// Returns the file that is shown when the post is displayed: the original
+// image if it can be decoded, otherwise (e.g. gif, webm) the thumbnail
+func (p Post) displayFile() (f remoteFile, err error) {
+	switch p.Ext {
+	case ".png", ".jpg":
+		return p.original()
+	default:
+		return p.thumbnail()
+	}
+}
+
+// Returns path to the cached file shown when the post is displayed
+func (p Post) displayPath() (fname string, err error) {
+	f, err := p.displayFile()
 	if err != nil {
-		return
+		return "", err
 	}
-	path, err := p.imagePath()
+	return f.path(), nil
+}
+
+var errNotFound = errors.New("not found")
+
+// Fetch an API response, using the cached copy if the server reports that it
+// has not been modified since. The cached copy is also used if the server
+// cannot be reached.
+func getJSON(url string) ([]byte, error) {
+	// e.g. g-thread-123.json
+	name := strings.ReplaceAll(strings.TrimPrefix(url, "https://a.4cdn.org/"), "/", "-")
+	path := apiCache.path(name)
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
-		return
+		return nil, err
 	}
 
-	if _, err := os.Stat(path); err == nil {
-		return
+	// mtime is the Last-Modified time of the cached response, as reported by
+	// the server, and is thus not bumped on use (unlike other caches). The
+	// local clock would miss posts made within a second of a fetch.
+	info, statErr := os.Stat(path)
+	if statErr == nil {
+		req.Header.Set("If-Modified-Since", info.ModTime().UTC().Format(http.TimeFormat))
 	}
 
-	log.Println("downloading", url)
-	resp, err := http.Get(url)
-	if err != nil {
-		panic(err)
+	resp, err := do(req)
+	switch {
+	case err != nil && statErr == nil:
+		log.Println("using cached", url, err)
+		return os.ReadFile(path)
+	case err != nil:
+		return nil, err
 	}
 	defer resp.Body.Close()
 
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return os.ReadFile(path)
+	case http.StatusOK:
+	case http.StatusNotFound: // e.g. pruned thread
+		_ = os.Remove(path)
+		return nil, fmt.Errorf("%s: %w", url, errNotFound)
+	default:
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+
 	b, err := io.ReadAll(resp.Body)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	_ = os.Mkdir(tmpDir, os.ModePerm)
-
-	if err := os.WriteFile(path, b, 0666); err != nil {
-		panic(err)
+	if err := apiCache.write(name, b); err != nil {
+		log.Println("failed to cache", url, err)
+	} else if t, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		_ = os.Chtimes(path, t, t)
 	}
+	return b, nil
 }
 
 type Catalog struct {
@@ -127,23 +257,25 @@ type Catalog struct {
 }
 
 func getCatalog(board string) Catalog {
-	url := fmt.Sprintf("https://a.4cdn.org/%s/catalog.json", board)
-	resp, err := http.Get(url)
+	c, err := fetchCatalog(board)
 	if err != nil {
 		panic(err)
 	}
-	defer resp.Body.Close()
+	return c
+}
 
-	b, err := io.ReadAll(resp.Body)
+func fetchCatalog(board string) (Catalog, error) {
+	url := fmt.Sprintf("https://a.4cdn.org/%s/catalog.json", board)
+	b, err := getJSON(url)
 	if err != nil {
-		panic(err)
+		return Catalog{}, err
 	}
 	var pages []struct {
 		Page    int
 		Threads []*Post
 	}
 	if err := json.Unmarshal(b, &pages); err != nil {
-		panic(err)
+		return Catalog{}, fmt.Errorf("%s: %w", url, err)
 	}
 	// fmt.Println(pages)
 
@@ -154,11 +286,33 @@ func getCatalog(board string) Catalog {
 
 	// ensure all posts have Board field set (otherwise leads to erroneous
 	// image urls)
-	for _, p := range threads {
+	for i, p := range threads {
 		p.Board = board
+		p.bumpOrder = i
 	}
+	go state.gcHidden(board, threads)
 
-	return Catalog{Board: board, Posts: threads}
+	return Catalog{Board: board, Posts: threads}, nil
+}
+
+// Returns the names of all boards, e.g. "g"
+func getBoards() (boards []string, err error) {
+	b, err := getJSON("https://a.4cdn.org/boards.json")
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Boards []struct {
+			Board string
+		}
+	}
+	if err := json.Unmarshal(b, &resp); err != nil {
+		return nil, err
+	}
+	for _, b := range resp.Boards {
+		boards = append(boards, b.Board)
+	}
+	return boards, nil
 }
 
 // Get thread by subject
@@ -196,42 +350,30 @@ func (t *Thread) getIndex(id int) (int, error) {
 	return 0, errors.New("post not found")
 }
 
-func (t *Thread) filterPosts(s string) (matches []int) {
-	for idx, p := range t.Posts {
-		if strings.Contains(strings.ToLower(p.Comment+p.Subject), s) {
-			matches = append(matches, idx)
-		}
-	}
-	return matches
-}
-
-func (t *Thread) cleanImages() {
-	for _, p := range t.Posts {
-		path, err := p.imagePath()
-		if err != nil {
-			continue
-		}
-		_ = os.Remove(path)
-	}
-}
-
 // Get thread by id
 func getThread(board string, id int) *Thread {
-	url := fmt.Sprintf("https://a.4cdn.org/%s/thread/%d.json", board, id)
-	// log.Println("getting", url)
-	resp, err := http.Get(url)
+	t, err := fetchThread(board, id)
 	if err != nil {
 		panic(err)
 	}
-	defer resp.Body.Close()
+	return t
+}
 
-	b, err := io.ReadAll(resp.Body)
+// Like getThread, but returns errors (errNotFound if the thread has been
+// pruned)
+func fetchThread(board string, id int) (*Thread, error) {
+	url := fmt.Sprintf("https://a.4cdn.org/%s/thread/%d.json", board, id)
+	// log.Println("getting", url)
+	b, err := getJSON(url)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	var t Thread
 	if err := json.Unmarshal(b, &t); err != nil {
-		panic(err)
+		return nil, err
+	}
+	if len(t.Posts) == 0 {
+		return nil, fmt.Errorf("%s: empty thread", url)
 	}
 	t.Board = board
 	// log.Printf(`getThread("%s", %d)`, board, id)
@@ -239,5 +381,14 @@ func getThread(board string, id int) *Thread {
 	for _, p := range t.Posts {
 		p.Board = t.Board
 	}
-	return &t
+	return &t, nil
+}
+
+// Number of the newest post. Once filtered, this is not necessarily the last
+// post, since pinned posts are moved to the top.
+func (t *Thread) newest() (num int) {
+	for _, p := range t.Posts {
+		num = max(num, p.Num)
+	}
+	return num
 }
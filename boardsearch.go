@@ -0,0 +1,227 @@
+// Cross-board search: find threads about a topic in the catalogs of several
+// boards
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Results of a cross-board search, shown in place of a catalog
+type boardSearch struct {
+	query  string
+	boards []string // empty for all boards
+	posts  []*Post  // matching OPs, tagged by their Board
+}
+
+// Fetch the catalogs of all boards searched (rate-limited and cached, like
+// any other catalog) and collect matching threads, in board order. Boards
+// that cannot be fetched are skipped; the returned error says which.
+func (bs *boardSearch) run(progress func(board string, i, n int)) error {
+	boards := bs.boards
+	if len(boards) == 0 {
+		all, err := getBoards()
+		if err != nil {
+			return err
+		}
+		boards = all
+	}
+
+	q := parseQuery(bs.query)
+	bs.posts = nil
+	var errs []error
+	for i, board := range boards {
+		if progress != nil {
+			progress(board, i, len(boards))
+		}
+		c, err := fetchCatalog(board)
+		if err != nil {
+			log.Println("search:", board, err)
+			errs = append(errs, err)
+			continue
+		}
+		for _, p := range filterPosts(board, c.Posts, true) {
+			if q.match(p) {
+				bs.posts = append(bs.posts, p)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (bs *boardSearch) String() string {
+	s := fmt.Sprintf("search %q", bs.query)
+	if len(bs.boards) > 0 {
+		s += " in /" + strings.Join(bs.boards, "/, /") + "/"
+	}
+	return s
+}
+
+// Usage: ibb search [flags] <query>
+func searchCmd(args []string) {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: ibb search [flags] <query>")
+		fs.PrintDefaults()
+	}
+	boards := fs.String("boards", "", "comma-separated boards to search (default all)")
+
+	pos := parseInterspersed(fs, args)
+	if len(pos) != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	bs := &boardSearch{query: pos[0]}
+	if *boards != "" {
+		bs.boards = strings.Split(*boards, ",")
+	}
+
+	err := bs.run(func(board string, i, n int) {
+		fmt.Fprintf(os.Stderr, "\r\033[Ksearching /%s/ (%d/%d)", board, i+1, n)
+	})
+	fmt.Fprint(os.Stderr, "\r\033[K")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	if len(bs.posts) == 0 {
+		fmt.Fprintln(os.Stderr, "no matches")
+		os.Exit(1)
+	}
+
+	p := tea.NewProgram(
+		newViewer(&buffer{thread: Thread{Posts: bs.posts}, catalog: true, results: bs}),
+		viewerOptions()...,
+	)
+	if _, err := p.Run(); err != nil {
+		panic(err)
+	}
+}
+
+// Sent when a cross-board search started from the viewer completes
+type boardSearchMsg struct {
+	search *boardSearch
+	err    error
+}
+
+// Run a cross-board search in the background
+func (m *ThreadViewer) searchBoards(query string, boards []string) tea.Cmd {
+	bs := &boardSearch{query: query, boards: boards}
+	m.status = bs.String() + "..."
+	return func() tea.Msg {
+		err := bs.run(nil)
+		return boardSearchMsg{search: bs, err: err}
+	}
+}
+
+// Show results of a cross-board search, in a new buffer (unless the current
+// buffer shows results already, e.g. when reloading)
+func (m *ThreadViewer) showResults(bs *boardSearch) {
+	if m.catalog && m.results != nil {
+		m.results = bs
+		m.replacePosts(bs.posts)
+		return
+	}
+	m.insertBuffer(&buffer{thread: Thread{Posts: bs.posts}, catalog: true, results: bs})
+	m.prefetch()
+}
+
+// Show the board list, from which boards can be opened or searched
+func (m *ThreadViewer) showBoards() tea.Cmd {
+	if m.panel == boardsPanel {
+		return m.closePanel()
+	}
+	if m.boards == nil {
+		boards, err := getBoards()
+		if err != nil {
+			m.status = "boards: " + err.Error()
+			return nil
+		}
+		m.boards = boards
+	}
+	return m.togglePanel(boardsPanel)
+}
+
+// Boards selected in the board list, in board order; nil (all boards) if
+// none
+func (m *ThreadViewer) selectedBoards() (boards []string) {
+	for _, board := range m.boards {
+		if m.selected[board] {
+			boards = append(boards, board)
+		}
+	}
+	return boards
+}
+
+// e.g. "all boards", "/g/, /v/"
+func (m *ThreadViewer) searchedBoards() string {
+	boards := m.selectedBoards()
+	if len(boards) == 0 {
+		return "all boards"
+	}
+	return "/" + strings.Join(boards, "/, /") + "/"
+}
+
+func (m *ThreadViewer) updateBoardsPanel(action string) tea.Cmd {
+	if m.panelCursor >= len(m.boards) {
+		return nil
+	}
+	board := m.boards[m.panelCursor]
+
+	switch action {
+	case "select-board":
+		if m.selected == nil {
+			m.selected = map[string]bool{}
+		}
+		m.selected[board] = !m.selected[board]
+		m.panelCursor = min(m.panelCursor+1, len(m.boards)-1)
+	case "search-boards":
+		m.typingQuery = true
+	case "open":
+		if b := m.findCatalog(board); b != nil {
+			m.switchTo(b)
+			return m.updateScreen()
+		}
+		c, err := fetchCatalog(board)
+		if err != nil {
+			m.status = err.Error()
+			return nil
+		}
+		m.insertBuffer(&buffer{})
+		m.openCatalog(c, state.catalogPosition(board))
+		return m.updateScreen()
+	}
+	return nil
+}
+
+// Handle a key while typing a query in the board list
+func (m *ThreadViewer) updateBoardQuery(msg tea.KeyMsg, a *action, ok bool) tea.Cmd {
+	var name string
+	if ok {
+		name = a.name
+	}
+	switch {
+	case name == "search-accept" && m.boardQuery != "":
+		m.typingQuery = false
+		return m.searchBoards(m.boardQuery, m.selectedBoards())
+	case name == "search-cancel":
+		m.typingQuery = false
+	case msg.Type == tea.KeyBackspace:
+		if m.boardQuery == "" {
+			m.typingQuery = false
+			break
+		}
+		r := []rune(m.boardQuery)
+		m.boardQuery = string(r[:len(r)-1])
+	case len(msg.Runes) > 0:
+		m.boardQuery += string(msg.Runes)
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+// Buffers: catalogs, threads and search results open at the same time, each
+// with its own position, search and read state. Shown one at a time, like
+// vim tabs.
+
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type buffer struct {
+	thread  Thread // contains .Posts
+	cursor  int
+	scroll  int          // index of the first post shown; see scrollWindow
+	show    paneMode     // initially config.Pane; see insertBuffer
+	catalog bool         // generally only affects View
+	sort    sortMode     // catalog only; persisted per board
+	results *boardSearch // if set, shown instead of the catalog
+	from    *buffer      // catalog or results a thread was opened from
+
+	// TODO: ambiguous field names: thread / catalog
+
+	searching  bool
+	input      string // search query; see parseQuery
+	matches    []int  // indices of matching posts; updated via buffer.updateMatches
+	searchFrom int    // cursor when search was started
+
+	lastRead int          // furthest post read in thread; persisted on leave
+	newSince int          // posts after this one are new since last visit
+	unread   map[int]bool // post numbers; updated on refresh, cleared on view
+
+	comment    viewport.Model // comment pane
+	commentFor int            // post shown in the comment pane
+
+	marks map[string]int // mark -> post number
+	jumps []int          // post numbers before each quote/mark jump
+}
+
+func newViewer(b *buffer) *ThreadViewer {
+	b.show = defaultPane()
+	return &ThreadViewer{
+		buffer:  b,
+		buffers: []*buffer{b},
+		arrange: config.Layout,
+		split:   config.Split,
+	}
+}
+
+// Thread shown by a thread buffer
+func (b *buffer) key() threadKey {
+	if b.catalog || len(b.thread.Posts) == 0 {
+		return threadKey{}
+	}
+	return threadKey{b.thread.Board, b.thread.Posts[0].Num}
+}
+
+// e.g. "/g/ catalog", "/g/123 subject [2 new]"
+func (b *buffer) String() string {
+	switch {
+	case b.results != nil:
+		return b.results.String()
+	case b.catalog:
+		return fmt.Sprintf("/%s/ catalog", b.thread.Board)
+	}
+	op := b.thread.Posts[0]
+	subject := op.Subject
+	if subject == "" {
+		subject = op.lineComment()
+	}
+	s := fmt.Sprintf("/%s/%d %s", b.thread.Board, op.Num, subject)
+	if n := len(b.unread); n > 0 {
+		s += fmt.Sprintf(" [%d new]", n)
+	}
+	return s
+}
+
+func (m *ThreadViewer) findThread(k threadKey) *buffer {
+	i := slices.IndexFunc(m.buffers, func(b *buffer) bool { return b.key() == k })
+	if i < 0 {
+		return nil
+	}
+	return m.buffers[i]
+}
+
+// Returns the open catalog of a board (not search results), if any
+func (m *ThreadViewer) findCatalog(board string) *buffer {
+	i := slices.IndexFunc(m.buffers, func(b *buffer) bool {
+		return b.catalog && b.results == nil && b.thread.Board == board
+	})
+	if i < 0 {
+		return nil
+	}
+	return m.buffers[i]
+}
+
+// Tell the scheduler which threads are open, so that all of them are
+// refreshed
+func (m *ThreadViewer) followOpen() {
+	var keys []threadKey
+	for _, b := range m.buffers {
+		if k := b.key(); k.board != "" {
+			keys = append(keys, k)
+		}
+	}
+	sched.follow(keys...)
+}
+
+func (m *ThreadViewer) switchTo(b *buffer) {
+	if b == m.buffer {
+		return
+	}
+	m.leave()
+	m.buffer = b
+	m.panel = noPanel
+}
+
+// Open a new buffer after the current one, and switch to it
+func (m *ThreadViewer) insertBuffer(b *buffer) {
+	b.show = defaultPane()
+	i := slices.Index(m.buffers, m.buffer)
+	m.buffers = slices.Insert(m.buffers, i+1, b)
+	m.switchTo(b)
+}
+
+// Open a thread at the given post in a new buffer, or switch to its buffer if
+// it is open already. If post is 0, the position is that of openThread.
+func (m *ThreadViewer) openTab(t *Thread, post int) {
+	if b := m.findThread(threadKey{t.Board, t.Posts[0].Num}); b != nil {
+		m.switchTo(b)
+		b.refreshPosts(t.Posts)
+		if idx, err := m.thread.getIndex(post); err == nil {
+			m.cursor = idx
+		}
+		return
+	}
+	m.insertBuffer(&buffer{})
+	m.openThread(t, post)
+}
+
+// Switch to the next (dir > 0) or previous buffer, wrapping around. With a
+// count, switch to buffer N, like Ngt in vim.
+func (m *ThreadViewer) cycleBuffer(dir int) tea.Cmd {
+	i := slices.Index(m.buffers, m.buffer)
+	switch {
+	case m.moveCount > 0 && dir > 0:
+		i = min(m.moveCount, len(m.buffers)) - 1
+	default:
+		i = (i + dir*m.count() + len(m.buffers)*m.count()) % len(m.buffers)
+	}
+	m.switchTo(m.buffers[i])
+	return nil
+}
+
+// Close a buffer; closing the last one quits
+func (m *ThreadViewer) closeBuffer(b *buffer) tea.Cmd {
+	if len(m.buffers) == 1 {
+		return m.quit()
+	}
+	i := slices.Index(m.buffers, b)
+	b.leave()
+	m.buffers = slices.Delete(m.buffers, i, i+1)
+	if b == m.buffer {
+		// like closing a tab: prefer the buffer it was opened from
+		next := m.buffers[max(0, i-1)]
+		if slices.Contains(m.buffers, b.from) {
+			next = b.from
+		}
+		m.buffer = next
+	}
+	m.followOpen()
+	return nil
+}
+
+// Position of the current buffer (1-based)
+func (m *ThreadViewer) bufferIndex() int {
+	return slices.Index(m.buffers, m.buffer) + 1
+}
+
+func (m *ThreadViewer) updateBuffersPanel(action string) tea.Cmd {
+	if m.panelCursor >= len(m.buffers) {
+		return nil
+	}
+	b := m.buffers[m.panelCursor]
+
+	switch action {
+	case "remove":
+		cmd := m.closeBuffer(b)
+		m.panelCursor = max(0, min(m.panelCursor, len(m.buffers)-1))
+		return cmd
+	case "open":
+		m.switchTo(b)
+		return m.updateScreen()
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuffers(t *testing.T) {
+	defer func(s *stateStore) { state = s }(state)
+	state = &stateStore{path: filepath.Join(t.TempDir(), "state.db")}
+
+	thread := func(num int) Thread {
+		return Thread{Board: "g", Posts: []*Post{{Board: "g", Num: num}}}
+	}
+	catalog := &buffer{thread: thread(1), catalog: true}
+	catalog.thread.Posts = append(catalog.thread.Posts, &Post{Board: "g", Num: 2})
+	m := newViewer(catalog)
+
+	a := &buffer{thread: thread(1), from: catalog}
+	m.insertBuffer(a)
+	m.switchTo(catalog)
+	b := &buffer{thread: thread(2), from: catalog}
+	m.insertBuffer(b) // after the catalog
+	assert.Equal(t, []*buffer{catalog, b, a}, m.buffers)
+	assert.Equal(t, 2, m.bufferIndex())
+
+	assert.Equal(t, b, m.findThread(threadKey{"g", 2}))
+	assert.Nil(t, m.findThread(threadKey{"g", 3}))
+	assert.Equal(t, catalog, m.findCatalog("g"))
+	assert.Nil(t, m.findCatalog("v"))
+	assert.Equal(t, "/g/ catalog", catalog.String())
+
+	m.cycleBuffer(1)
+	assert.Equal(t, a, m.buffer)
+	m.cycleBuffer(1) // wraps
+	assert.Equal(t, catalog, m.buffer)
+	m.cycleBuffer(-1)
+	assert.Equal(t, a, m.buffer)
+	m.moveCount = 2 // 2gt
+	m.cycleBuffer(1)
+	assert.Equal(t, b, m.buffer)
+	m.moveCount = 0
+
+	m.back() // to the catalog it was opened from, without fetching
+	assert.Equal(t, catalog, m.buffer)
+	assert.Equal(t, 1, m.cursor)
+
+	m.switchTo(a)
+	m.closeBuffer(a)
+	assert.Equal(t, catalog, m.buffer)
+	assert.Equal(t, []*buffer{catalog, b}, m.buffers)
+	assert.Equal(t, []threadKey{{"g", 2}}, sched.open)
+}
@@ -0,0 +1,303 @@
+// Persistent on-disk cache, shared between concurrent ibb instances. All
+// writes go through a temp file + rename, so readers never see partial files.
+
+package main
+
+import (
+	"container/list"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A directory of downloaded files, bounded in total size and age. Eviction is
+// least recently used, where "use" is approximated by mtime, which is bumped
+// on every cache hit.
+type diskCache struct {
+	dir     string
+	maxSize int64 // in bytes
+	maxAge  time.Duration
+	mu      sync.Mutex
+}
+
+// $XDG_CACHE_HOME/ibb
+var cacheRoot = func() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "ibb")
+}()
+
+var (
+	originals  = newDiskCache("originals", 1<<30, 7*24*time.Hour)
+	thumbnails = newDiskCache("thumbnails", 128<<20, 30*24*time.Hour)
+	renders    = newDiskCache("renders", 256<<20, 24*time.Hour)
+	apiCache   = newDiskCache("json", 64<<20, 7*24*time.Hour)
+)
+
+// All namespaces, in the order shown by `ibb cache stats`
+var caches = []*diskCache{originals, thumbnails, renders, apiCache}
+
+func newDiskCache(namespace string, maxSize int64, maxAge time.Duration) *diskCache {
+	return &diskCache{
+		dir:     filepath.Join(cacheRoot, namespace),
+		maxSize: maxSize,
+		maxAge:  maxAge,
+	}
+}
+
+func (c *diskCache) name() string {
+	return filepath.Base(c.dir)
+}
+
+func (c *diskCache) path(name string) string {
+	return filepath.Join(c.dir, name)
+}
+
+// Check if a file exists in the cache, marking it as recently used if it does
+func (c *diskCache) has(name string) bool {
+	path := c.path(name)
+	if _, err := os.Stat(path); err != nil {
+		return false
+	}
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return true
+}
+
+// Atomically write a file to the cache
+func (c *diskCache) write(name string, b []byte) error {
+	f, err := c.tempFile(name)
+	if err != nil {
+		return err
+	}
+	defer c.discard(f)
+
+	if _, err := f.Write(b); err != nil {
+		return err
+	}
+	return c.commit(f, name)
+}
+
+// Create a temp file in the cache, to be moved into place with commit. Temp
+// files are hidden, so that prune can tell them apart.
+func (c *diskCache) tempFile(name string) (*os.File, error) {
+	if err := os.MkdirAll(c.dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	return os.CreateTemp(c.dir, "."+name+".*")
+}
+
+func (c *diskCache) commit(f *os.File, name string) error {
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), c.path(name))
+}
+
+// Clean up a temp file; no-op after a successful commit
+func (c *diskCache) discard(f *os.File) {
+	f.Close()
+	_ = os.Remove(f.Name())
+}
+
+type cacheEntry struct {
+	path  string
+	size  int64
+	mtime time.Time
+}
+
+func (c *diskCache) entries() (entries []cacheEntry) {
+	_ = filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		entries = append(entries, cacheEntry{path: path, size: info.Size(), mtime: info.ModTime()})
+		return nil
+	})
+	return entries
+}
+
+// Remove files older than maxAge, then least recently used files until the
+// total size of the cache is within maxSize. Temp files are only removed once
+// they are old enough to have been abandoned (e.g. by a killed process);
+// otherwise they may belong to a download still in progress elsewhere.
+func (c *diskCache) prune() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var kept []cacheEntry
+	var total int64
+	for _, e := range c.entries() {
+		age := time.Since(e.mtime)
+		isTemp := strings.HasPrefix(filepath.Base(e.path), ".")
+		switch {
+		case isTemp && age < time.Hour:
+		case isTemp || age > c.maxAge:
+			// another instance may have removed it already
+			_ = os.Remove(e.path)
+			continue
+		default:
+			kept = append(kept, e)
+		}
+		total += e.size
+	}
+
+	if total <= c.maxSize {
+		return
+	}
+
+	slices.SortFunc(kept, func(a, b cacheEntry) int { return a.mtime.Compare(b.mtime) })
+
+	for _, e := range kept {
+		if total <= c.maxSize {
+			break
+		}
+		if err := os.Remove(e.path); err != nil {
+			continue
+		}
+		total -= e.size
+	}
+	log.Println("pruned", c.dir, "to", total, "bytes")
+}
+
+// In-memory LRU cache, optionally backed by a diskCache
+type lruCache struct {
+	capacity int // number of entries
+	disk     *diskCache
+
+	mu    sync.Mutex
+	order *list.List // most recently used first; values are *lruEntry
+	items map[string]*list.Element
+}
+
+type lruEntry struct {
+	key string
+	val []byte
+}
+
+// Rendered (resized and encoded) images. Set disk to nil to only cache in
+// memory.
+var renderCache = newLRUCache(64, renders)
+
+func newLRUCache(capacity int, disk *diskCache) *lruCache {
+	return &lruCache{
+		capacity: capacity,
+		disk:     disk,
+		order:    list.New(),
+		items:    map[string]*list.Element{},
+	}
+}
+
+func (c *lruCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	if el, ok := c.items[key]; ok {
+		c.order.MoveToFront(el)
+		c.mu.Unlock()
+		return el.Value.(*lruEntry).val, true
+	}
+	c.mu.Unlock()
+
+	if c.disk == nil || !c.disk.has(key) {
+		return nil, false
+	}
+	b, err := os.ReadFile(c.disk.path(key))
+	if err != nil {
+		return nil, false
+	}
+	c.add(key, b)
+	return b, true
+}
+
+func (c *lruCache) put(key string, b []byte) {
+	c.add(key, b)
+	if c.disk == nil {
+		return
+	}
+	if err := c.disk.write(key, b); err != nil {
+		log.Println("failed to cache", key, err)
+	}
+}
+
+// Add to memory only
+func (c *lruCache) add(key string, b []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		el.Value.(*lruEntry).val = b
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(&lruEntry{key: key, val: b})
+
+	for c.order.Len() > c.capacity {
+		el := c.order.Back()
+		c.order.Remove(el)
+		delete(c.items, el.Value.(*lruEntry).key)
+	}
+}
+
+func pruneCaches() {
+	for _, c := range caches {
+		c.prune()
+	}
+}
+
+func (c *diskCache) clear() error {
+	return os.RemoveAll(c.dir)
+}
+
+// Usage: ibb cache stats|prune|clear
+func cacheCmd(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: ibb cache stats|prune|clear")
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "stats":
+		fmt.Println(cacheRoot)
+		for _, c := range caches {
+			var total int64
+			entries := c.entries()
+			for _, e := range entries {
+				total += e.size
+			}
+			fmt.Printf(
+				"%-12s %6d files %8.1f / %.0f MB (max age %s)\n",
+				c.name(),
+				len(entries),
+				float64(total)/(1<<20),
+				float64(c.maxSize)/(1<<20),
+				c.maxAge,
+			)
+		}
+
+	case "prune":
+		pruneCaches()
+
+	case "clear":
+		for _, c := range caches {
+			if err := c.clear(); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
+
+	default:
+		fmt.Fprintln(os.Stderr, "unknown cache command:", args[0])
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiskCache(t *testing.T) {
+	c := &diskCache{dir: t.TempDir(), maxSize: 10, maxAge: time.Hour}
+
+	for i, name := range []string{"a", "b", "c", "d"} {
+		assert.NoError(t, c.write(name, []byte("1234")))
+		mtime := time.Now().Add(time.Duration(i-10) * time.Minute)
+		if name == "d" {
+			mtime = mtime.Add(-2 * time.Hour)
+		}
+		assert.NoError(t, os.Chtimes(c.path(name), mtime, mtime))
+	}
+
+	// a is now the most recently used
+	assert.True(t, c.has("a"))
+	assert.False(t, c.has("e"))
+
+	// d is too old, b is least recently used
+	c.prune()
+	assert.True(t, c.has("a"))
+	assert.False(t, c.has("b"))
+	assert.True(t, c.has("c"))
+	assert.False(t, c.has("d"))
+
+	// no temp files left behind
+	assert.Len(t, c.entries(), 2)
+}
+
+func TestLRUCache(t *testing.T) {
+	c := newLRUCache(2, nil)
+	c.put("a", []byte("a"))
+	c.put("b", []byte("b"))
+	_, _ = c.get("a")
+	c.put("c", []byte("c"))
+
+	_, ok := c.get("b")
+	assert.False(t, ok)
+	b, ok := c.get("a")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("a"), b)
+
+	// evicted from memory, but still on disk
+	c = newLRUCache(0, &diskCache{dir: t.TempDir()})
+	c.put("a", []byte("a"))
+	b, ok = c.get("a")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("a"), b)
+}
@@ -0,0 +1,120 @@
+// Comment pane: a scrollable viewport below (or beside) the posts list
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Render the (styled) comment of post num into the viewport, which takes up
+// the given pane, flowing around the image (if not nil). The viewport is
+// scrolled back to the top whenever the post changes.
+func (m *ThreadViewer) viewComment(num int, body string, pane, image *Size) string {
+	if m.commentFor != num {
+		m.comment = viewport.New(0, 0)
+		m.commentFor = num
+	}
+
+	switch {
+	case image != nil:
+		body = strings.Join(flowComment(body, pane.width, image.width+1, image.height), "\n")
+	default:
+		body = lipgloss.NewStyle().Width(pane.width).Render(body)
+	}
+
+	m.comment.Width = pane.width
+	m.comment.Height = max(1, pane.height-1) // leave a line for the indicator
+	m.comment.SetContent(body)
+
+	m.layout.commentTop = pane.y
+	m.layout.commentLeft = pane.x
+	lines := strings.Split(body, "\n")
+	for _, line := range lines[m.comment.YOffset:min(len(lines), m.comment.YOffset+m.comment.Height)] {
+		m.layout.comment = append(m.layout.comment, strings.TrimRight(ansi.Strip(line), " "))
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Left, m.comment.View(), m.scrollIndicator())
+}
+
+// Narrowest text worth showing beside an image
+const minFlowWidth = 20
+
+// Wrap a (styled) comment to width, around an image in the top left corner:
+// the first rows lines are indented past the image, and the rest take the
+// full width. A paragraph that starts beside the image continues below it.
+func flowComment(body string, width, indent, rows int) (lines []string) {
+	wrap := func(s string, w int) []string {
+		return strings.Split(lipgloss.NewStyle().Width(w).Render(s), "\n")
+	}
+	if width-indent < minFlowWidth { // start below the image
+		lines = make([]string, rows)
+	}
+	pad := strings.Repeat(" ", indent)
+
+	for _, para := range strings.Split(body, "\n") {
+		if len(lines) >= rows {
+			lines = append(lines, wrap(para, width)...)
+			continue
+		}
+		wrapped := wrap(para, width-indent)
+		n := min(len(wrapped), rows-len(lines))
+		for _, line := range wrapped[:n] {
+			lines = append(lines, pad+line)
+		}
+		if n < len(wrapped) {
+			for i, line := range wrapped {
+				wrapped[i] = strings.TrimRight(line, " ") // padding
+			}
+			lines = append(lines, wrap(strings.Join(wrapped[n:], " "), width)...)
+		}
+	}
+	return lines
+}
+
+// e.g. "12-40/80 (50%)", right-aligned; empty if the whole comment fits
+func (m *ThreadViewer) scrollIndicator() string {
+	total := m.comment.TotalLineCount()
+	if total <= m.comment.Height {
+		return ""
+	}
+	s := fmt.Sprintf(
+		"%d-%d/%d (%d%%)",
+		m.comment.YOffset+1,
+		min(total, m.comment.YOffset+m.comment.Height),
+		total,
+		int(m.comment.ScrollPercent()*100),
+	)
+	return config.styles.hidden.Width(m.comment.Width).Align(lipgloss.Right).Render(s)
+}
+
+// Whether the comment pane is shown, and thus can be scrolled
+func (m *ThreadViewer) commentShown() bool {
+	_, text := m.paneAreas(m.currentPost())
+	return text != nil
+}
+
+// Scroll the comment pane. Redraws if this hides or shows the image (see
+// paneAreas).
+func (m *ThreadViewer) scrollComment(lines int) tea.Cmd {
+	if !m.commentShown() {
+		m.status = "comment not shown"
+		return nil
+	}
+	before, _ := m.paneAreas(m.currentPost())
+	switch {
+	case lines > 0:
+		m.comment.LineDown(lines)
+	case lines < 0:
+		m.comment.LineUp(-lines)
+	}
+	if after, _ := m.paneAreas(m.currentPost()); (before == nil) != (after == nil) {
+		return m.updateScreen()
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommentPane(t *testing.T) {
+	config.styles = themes["monochrome"]
+	long := strings.Join(strings.Fields("a b c d e f g h i j"), "\n")
+	m := ThreadViewer{buffer: &buffer{show: paneComment}, width: 20, height: 6}
+	m.thread.Posts = []*Post{{Num: 1, Time: 1, Comment: "a"}}
+	pane := &Size{width: 20, height: 6}
+
+	out := m.viewComment(1, long, pane, nil) // 5 lines, then the indicator
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, m.layout.comment)
+	assert.Contains(t, out, "1-5/10 (0%)")
+
+	m.scrollComment(3)
+	m.layout = layout{}
+	out = m.viewComment(1, long, pane, nil)
+	assert.Equal(t, "d", m.layout.comment[0])
+	assert.Contains(t, out, "4-8/10")
+
+	m.scrollComment(100) // clamped to the end
+	assert.Equal(t, 5, m.comment.YOffset)
+
+	// reset on the next post, without an indicator
+	m.layout = layout{}
+	out = m.viewComment(2, "short", pane, nil)
+	assert.Equal(t, 0, m.comment.YOffset)
+	assert.NotContains(t, out, "/")
+
+	m.show = paneImage
+	m.scrollComment(1)
+	assert.Equal(t, "comment not shown", m.status)
+}
+
+func TestFlowComment(t *testing.T) {
+	body := "one two three four five six seven eight nine ten eleven\nend"
+
+	// beside the image, then the rest of the paragraph at full width
+	lines := flowComment(body, 50, 30, 2)
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], " ")
+	}
+	pad := strings.Repeat(" ", 30)
+	assert.Equal(t, []string{
+		pad + "one two three four",
+		pad + "five six seven eight",
+		"nine ten eleven",
+		"end",
+	}, lines)
+
+	// too narrow: below the image
+	lines = flowComment(body, 30, 15, 2)
+	assert.Equal(t, []string{"", ""}, lines[:2])
+	assert.Equal(t, "one two three four five six", strings.TrimRight(lines[2], " "))
+}
@@ -0,0 +1,151 @@
+// User configuration, read from $XDG_CONFIG_HOME/ibb/config.yaml. All fields
+// are optional; missing fields keep their defaults.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	Save    SaveConfig   `yaml:"save"`
+	Filters []FilterRule `yaml:"filters"`
+	Hide    HideConfig   `yaml:"hide"`
+	// Action name -> keys; replaces the default keys of the action
+	Keys map[string]keyList `yaml:"keys"`
+	// default, solarized, high-contrast or monochrome
+	Theme string `yaml:"theme"`
+	// Capture the mouse (which prevents selecting text in most terminals)
+	Mouse bool `yaml:"mouse"`
+	// Line numbers in the posts list: none, absolute or relative
+	LineNumbers string `yaml:"line_numbers"`
+	// Position of the image/comment pane: auto, stacked (below the list) or
+	// side-by-side
+	Layout string `yaml:"layout"`
+	// Percent of the screen taken by the list
+	Split int `yaml:"split"`
+	// What the pane shows by default: image, comment or both
+	Pane string `yaml:"pane"`
+
+	keymap keymap // built by validate
+	styles theme  // likewise
+}
+
+type HideConfig struct {
+	// Also hide replies to manually hidden posts (and replies to those)
+	Recursive bool `yaml:"recursive"`
+}
+
+type SaveConfig struct {
+	// Destination of saved images; see expandTemplate for placeholders
+	Dest string `yaml:"dest"`
+	// What to do if the destination exists: skip, rename or overwrite
+	Collision string `yaml:"collision"`
+}
+
+func defaultConfig() Config {
+	return Config{
+		Save: SaveConfig{
+			Dest:      "~/{subject}/{tim}{ext}",
+			Collision: "skip",
+		},
+		Theme:       "default",
+		Mouse:       true,
+		LineNumbers: "none",
+		Layout:      "auto",
+		Split:       50,
+		Pane:        "image",
+	}
+}
+
+// Replaced by loadConfig on startup
+var config = defaultConfig()
+
+func configPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "ibb", "config.yaml")
+}
+
+// Read config file, if any. Unknown fields are an error, as they are most
+// likely typos.
+func loadConfig() (Config, error) {
+	cfg := defaultConfig()
+
+	path := configPath()
+	f, err := os.Open(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist) || path == "":
+		return cfg, cfg.validate()
+	case err != nil:
+		return cfg, err
+	}
+	defer f.Close()
+
+	if err := decodeConfig(f, &cfg); err != nil {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func decodeConfig(r io.Reader, cfg *Config) error {
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && err != io.EOF {
+		return err
+	}
+	return cfg.validate()
+}
+
+// Check config, compile filters, and build the keymap and theme
+func (cfg *Config) validate() error {
+	switch cfg.Save.Collision {
+	case "skip", "rename", "overwrite":
+	default:
+		return fmt.Errorf("save.collision: must be skip, rename or overwrite, got %q", cfg.Save.Collision)
+	}
+	if _, err := expandTemplate(cfg.Save.Dest, saveVars{}); err != nil {
+		return fmt.Errorf("save.dest: %w", err)
+	}
+	switch cfg.LineNumbers {
+	case "none", "absolute", "relative":
+	default:
+		return fmt.Errorf("line_numbers: must be none, absolute or relative, got %q", cfg.LineNumbers)
+	}
+	if !slices.Contains(arrangements, cfg.Layout) {
+		return fmt.Errorf("layout: must be one of %v, got %q", arrangements, cfg.Layout)
+	}
+	if cfg.Split < minSplit || cfg.Split > maxSplit {
+		return fmt.Errorf("split: must be between %d and %d, got %d", minSplit, maxSplit, cfg.Split)
+	}
+	if !slices.Contains(paneModes, cfg.Pane) {
+		return fmt.Errorf("pane: must be one of %v, got %q", paneModes, cfg.Pane)
+	}
+	km, err := newKeymap(cfg.Keys)
+	if err != nil {
+		return fmt.Errorf("keys: %w", err)
+	}
+	cfg.keymap = km
+
+	styles, ok := getTheme(cfg.Theme)
+	if !ok {
+		return fmt.Errorf("theme: must be one of %v, got %q", themeNames(), cfg.Theme)
+	}
+	cfg.styles = styles
+
+	for i := range cfg.Filters {
+		if err := cfg.Filters[i].compile(); err != nil {
+			return fmt.Errorf("filters[%d].%w", i, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+// Bulk download of all images in a thread
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/sync/errgroup"
+)
+
+type dlOptions struct {
+	onlyExt   []string // including leading dot; empty means all
+	minSize   int      // in bytes
+	sincePost int      // only posts with at least this number
+	workers   int
+}
+
+var defaultDlOptions = dlOptions{workers: 4}
+
+type dlSummary struct {
+	saved      int
+	skipped    int // already saved
+	duplicates int // same md5 as another post in the thread
+	failed     int
+}
+
+func (s dlSummary) String() string {
+	return fmt.Sprintf(
+		"%d saved, %d skipped, %d duplicates, %d failed",
+		s.saved, s.skipped, s.duplicates, s.failed,
+	)
+}
+
+func (s *dlSummary) add(other dlSummary) {
+	s.saved += other.saved
+	s.skipped += other.skipped
+	s.duplicates += other.duplicates
+	s.failed += other.failed
+}
+
+func (opts dlOptions) wants(p *Post) bool {
+	switch {
+	case p.Time == 0:
+		return false
+	case len(opts.onlyExt) > 0 && !slices.ContainsFunc(opts.onlyExt, func(ext string) bool {
+		return strings.EqualFold(ext, p.Ext)
+	}):
+		return false
+	case p.Size < opts.minSize:
+		return false
+	case p.Num < opts.sincePost:
+		return false
+	}
+	return true
+}
+
+// Save all images in a thread (see Post.saveImage), with bounded
+// concurrency. Images with the same md5 are only saved once. Images that were
+// already saved are skipped, so an interrupted download can simply be run
+// again. report, if not nil, is called (serially) after each post.
+func downloadThread(t *Thread, opts dlOptions, report func(p *Post, dest string, err error)) (sum dlSummary) {
+	var mu sync.Mutex // guards sum and report
+	var g errgroup.Group
+	g.SetLimit(max(1, opts.workers))
+
+	// images before sincePost are assumed to have been saved already (e.g.
+	// by a previous run), so reposts of them are duplicates too
+	seen := map[string]bool{}
+	for _, p := range t.Posts {
+		if p.Num < opts.sincePost && p.MD5 != "" {
+			seen[p.MD5] = true
+		}
+	}
+
+	for _, p := range t.Posts {
+		if !opts.wants(p) {
+			continue
+		}
+		if p.MD5 != "" && seen[p.MD5] {
+			sum.duplicates++
+			continue
+		}
+		seen[p.MD5] = true
+
+		g.Go(func() error {
+			dest, saved, err := p.saveImage(t.Posts[0])
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err != nil:
+				sum.failed++
+			case saved:
+				sum.saved++
+			default:
+				sum.skipped++
+			}
+			if report != nil {
+				report(p, dest, err)
+			}
+			return nil
+		})
+	}
+	_ = g.Wait()
+	return sum
+}
+
+type dlDoneMsg struct{ summary dlSummary }
+
+// Download all images in the current thread in the background
+func (m *ThreadViewer) downloadAll() tea.Cmd {
+	t := m.thread
+	return func() tea.Msg {
+		return dlDoneMsg{downloadThread(&t, defaultDlOptions, nil)}
+	}
+}
+
+var threadUrl = regexp.MustCompile(`/thread/(\d+)`)
+
+// Accepts either a thread number or a thread url
+func parseThreadArg(s string) (int, error) {
+	if id, err := strconv.Atoi(s); err == nil {
+		return id, nil
+	}
+	if m := threadUrl.FindStringSubmatch(s); m != nil {
+		return strconv.Atoi(m[1])
+	}
+	return 0, fmt.Errorf("not a thread: %s", s)
+}
+
+// Parse a size such as 500, 500K or 2M (in bytes)
+func parseSize(s string) (int, error) {
+	mult := 1
+	switch {
+	case strings.HasSuffix(strings.ToUpper(s), "K"):
+		mult = 1 << 10
+	case strings.HasSuffix(strings.ToUpper(s), "M"):
+		mult = 1 << 20
+	}
+	if mult > 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, errors.New("invalid size: " + s)
+	}
+	return n * mult, nil
+}
+
+// Parse flags that may appear before, between or after positional arguments
+func parseInterspersed(fs *flag.FlagSet, args []string) (positional []string) {
+	for {
+		_ = fs.Parse(args) // ExitOnError
+		if fs.NArg() == 0 {
+			return positional
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// Usage: ibb dl [flags] <board> <thread|url>
+func dlCmd(args []string) {
+	opts := defaultDlOptions
+
+	fs := flag.NewFlagSet("dl", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: ibb dl [flags] <board> <thread|url>")
+		fs.PrintDefaults()
+	}
+	onlyExt := fs.String("only-ext", "", "comma-separated extensions to download, e.g. jpg,png")
+	minSize := fs.String("min-size", "0", "minimum file size, e.g. 500K, 2M")
+	fs.IntVar(&opts.sincePost, "since-post", 0, "only download from posts with at least this number")
+	fs.IntVar(&opts.workers, "j", opts.workers, "number of concurrent downloads")
+
+	pos := parseInterspersed(fs, args)
+	if len(pos) != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	var err error
+	if opts.minSize, err = parseSize(*minSize); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	for _, ext := range strings.Split(*onlyExt, ",") {
+		if ext = strings.TrimSpace(ext); ext != "" {
+			opts.onlyExt = append(opts.onlyExt, "."+strings.TrimPrefix(ext, "."))
+		}
+	}
+
+	board := pos[0]
+	id, err := parseThreadArg(pos[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	t := getThread(board, id)
+	sum := downloadThread(t, opts, func(p *Post, dest string, err error) {
+		if err != nil {
+			log.Println(p.Num, err)
+			fmt.Fprintln(os.Stderr, p.Num, err)
+			return
+		}
+		fmt.Println(dest)
+	})
+	fmt.Println(sum)
+	if sum.failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDlArgs(t *testing.T) {
+	for s, want := range map[string]int{
+		"123":                                   123,
+		"https://boards.4chan.org/g/thread/123": 123,
+		"https://boards.4chan.org/g/thread/123/foo#p456": 123,
+	} {
+		id, err := parseThreadArg(s)
+		assert.NoError(t, err)
+		assert.Equal(t, want, id)
+	}
+	_, err := parseThreadArg("g")
+	assert.Error(t, err)
+
+	for s, want := range map[string]int{"0": 0, "500": 500, "500K": 500 << 10, "2m": 2 << 20} {
+		n, err := parseSize(s)
+		assert.NoError(t, err)
+		assert.Equal(t, want, n)
+	}
+	_, err = parseSize("K")
+	assert.Error(t, err)
+
+	opts := dlOptions{onlyExt: []string{".jpg"}, minSize: 100, sincePost: 10}
+	assert.True(t, opts.wants(&Post{Time: 1, Ext: ".JPG", Size: 100, Num: 10}))
+	assert.False(t, opts.wants(&Post{Time: 1, Ext: ".png", Size: 100, Num: 10}))
+	assert.False(t, opts.wants(&Post{Time: 1, Ext: ".jpg", Size: 99, Num: 10}))
+	assert.False(t, opts.wants(&Post{Time: 1, Ext: ".jpg", Size: 100, Num: 9}))
+	assert.False(t, opts.wants(&Post{Ext: ".jpg", Size: 100, Num: 10}))
+}
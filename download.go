@@ -0,0 +1,127 @@
+// Streaming, verified file downloads
+
+package main
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+// A file on the image CDN, and where it is cached. size and md5 are only
+// known for originals (not thumbnails); if unknown, they are not checked.
+type remoteFile struct {
+	url   string
+	cache *diskCache
+	size  int64
+	md5   string // base64, as in the API
+}
+
+func (f remoteFile) name() string { return filepath.Base(f.url) }
+func (f remoteFile) path() string { return f.cache.path(f.name()) }
+
+const downloadAttempts = 3
+
+// Progress of downloads in flight, keyed by url
+var progress sync.Map // map[string]*downloadProgress
+
+type downloadProgress struct {
+	done  atomic.Int64
+	total int64 // may be 0 if unknown
+}
+
+func (dp *downloadProgress) Write(b []byte) (int, error) {
+	dp.done.Add(int64(len(b)))
+	return len(b), nil
+}
+
+// Returns the fraction of a file downloaded so far, or false if the file is
+// not being downloaded (or its size is unknown)
+func downloadProgressOf(url string) (float64, bool) {
+	v, ok := progress.Load(url)
+	if !ok {
+		return 0, false
+	}
+	dp := v.(*downloadProgress)
+	if dp.total == 0 {
+		return 0, false
+	}
+	return float64(dp.done.Load()) / float64(dp.total), true
+}
+
+// Download a file to the cache, unless it is already there. The file is
+// streamed to a temp file, verified, and only then moved into place, so a
+// killed process never leaves a truncated file behind. Downloads that fail
+// verification are retried.
+//
+// Generally, downloads.fetch should be used instead, which avoids downloading
+// the same file twice concurrently.
+func download(f remoteFile) (err error) {
+	if f.cache.has(f.name()) {
+		return nil
+	}
+
+	for attempt := 1; attempt <= downloadAttempts; attempt++ {
+		log.Println("downloading", f.url, "attempt", attempt)
+		err = f.fetch()
+		if err == nil || !isVerifyError(err) {
+			return err
+		}
+		log.Println(err)
+	}
+	return err
+}
+
+type verifyError struct{ msg string }
+
+func (e verifyError) Error() string { return e.msg }
+
+func isVerifyError(err error) bool {
+	_, ok := err.(verifyError)
+	return ok
+}
+
+func (f remoteFile) fetch() error {
+	// stored before the request, so that time spent waiting on the rate
+	// limiter also counts as in progress
+	dp := &downloadProgress{total: f.size}
+	progress.Store(f.url, dp)
+	defer progress.Delete(f.url)
+
+	resp, err := get(f.url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", f.url, resp.Status)
+	}
+
+	tmp, err := f.cache.tempFile(f.name())
+	if err != nil {
+		return err
+	}
+	defer f.cache.discard(tmp)
+
+	hash := md5.New()
+	n, err := io.Copy(io.MultiWriter(tmp, hash, dp), resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if f.size > 0 && n != f.size {
+		return verifyError{fmt.Sprintf("%s: expected %d bytes, got %d", f.url, f.size, n)}
+	}
+	if sum := base64.StdEncoding.EncodeToString(hash.Sum(nil)); f.md5 != "" && sum != f.md5 {
+		return verifyError{fmt.Sprintf("%s: expected md5 %s, got %s", f.url, f.md5, sum)}
+	}
+
+	return f.cache.commit(tmp, f.name())
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownload(t *testing.T) {
+	body := []byte("not really an image")
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write(body)
+	}))
+	defer srv.Close()
+
+	c := &diskCache{dir: t.TempDir()}
+	sum := md5.Sum(body)
+	f := remoteFile{
+		url:   srv.URL + "/123.jpg",
+		cache: c,
+		size:  int64(len(body)),
+		md5:   base64.StdEncoding.EncodeToString(sum[:]),
+	}
+
+	assert.NoError(t, download(f))
+	b, err := os.ReadFile(f.path())
+	assert.NoError(t, err)
+	assert.Equal(t, body, b)
+
+	// cached
+	assert.NoError(t, download(f))
+	assert.Equal(t, 1, requests)
+
+	// mismatched files are retried, then discarded
+	f.url = srv.URL + "/456.jpg"
+	f.md5 = "AAAAAAAAAAAAAAAAAAAAAA=="
+	assert.Error(t, download(f))
+	assert.Equal(t, 1+downloadAttempts, requests)
+	assert.Len(t, c.entries(), 1)
+}
+
+func TestGetJSON(t *testing.T) {
+	defer func(c *diskCache) { apiCache = c }(apiCache)
+	apiCache = &diskCache{dir: t.TempDir()}
+
+	modified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	var since []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		since = append(since, r.Header.Get("If-Modified-Since"))
+		if r.Header.Get("If-Modified-Since") != "" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+		_, _ = w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+
+	// the server's time is sent back, and kept on 304
+	for range 2 {
+		b, err := getJSON(srv.URL + "/g/thread/1.json")
+		assert.NoError(t, err)
+		assert.Equal(t, "{}", string(b))
+	}
+	want := modified.Format(http.TimeFormat)
+	assert.Equal(t, []string{"", want}, since)
+	_, err := getJSON(srv.URL + "/g/thread/1.json")
+	assert.NoError(t, err)
+	assert.Equal(t, want, since[2])
+}
@@ -0,0 +1,191 @@
+// Filters, in the style of 4chan X: hide, highlight or pin posts whose
+// fields match a rule from the config
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sync/atomic"
+)
+
+type FilterRule struct {
+	// subject, comment, name, trip, id, md5, filename or country
+	Field string `yaml:"field"`
+	// Regex, or the whole value if exact
+	Pattern string `yaml:"pattern"`
+	Exact   bool   `yaml:"exact"`
+	// Boards the rule applies to; all if empty
+	Boards []string `yaml:"boards"`
+	// catalog or thread; both if empty
+	Scope string `yaml:"scope"`
+	// hide, highlight or pin
+	Action string `yaml:"action"`
+	// Highlight colour, as accepted by lipgloss (e.g. "1" or "#ff0000")
+	Color string `yaml:"color"`
+
+	re *regexp.Regexp
+}
+
+var filterFields = []string{"subject", "comment", "name", "trip", "id", "md5", "filename", "country"}
+
+// Check rule, and compile its pattern
+func (r *FilterRule) compile() error {
+	if !slices.Contains(filterFields, r.Field) {
+		return fmt.Errorf("field: must be one of %v, got %q", filterFields, r.Field)
+	}
+	switch r.Scope {
+	case "", "catalog", "thread":
+	default:
+		return fmt.Errorf("scope: must be catalog or thread, got %q", r.Scope)
+	}
+	switch r.Action {
+	case "hide", "pin":
+	case "highlight":
+		if r.Color == "" {
+			return errors.New("color: required to highlight")
+		}
+	default:
+		return fmt.Errorf("action: must be hide, highlight or pin, got %q", r.Action)
+	}
+	if r.Exact {
+		return nil
+	}
+	re, err := regexp.Compile(r.Pattern)
+	if err != nil {
+		return fmt.Errorf("pattern: %w", err)
+	}
+	r.re = re
+	return nil
+}
+
+func (r *FilterRule) value(p *Post) string {
+	switch r.Field {
+	case "subject":
+		return p.Subject
+	case "comment":
+		return p.text()
+	case "name":
+		return p.Name
+	case "trip":
+		return p.Trip
+	case "id":
+		return p.ID
+	case "md5":
+		return p.MD5
+	case "filename":
+		if p.Ext == "" {
+			return ""
+		}
+		return p.Filename + p.Ext
+	default:
+		return p.Country
+	}
+}
+
+func (r *FilterRule) applies(board string, catalog bool) bool {
+	switch {
+	case len(r.Boards) > 0 && !slices.Contains(r.Boards, board):
+		return false
+	case r.Scope == "catalog":
+		return catalog
+	case r.Scope == "thread":
+		return !catalog
+	default:
+		return true
+	}
+}
+
+func (r *FilterRule) match(p *Post) bool {
+	v := r.value(p)
+	if r.Exact {
+		return v == r.Pattern
+	}
+	return v != "" && r.re.MatchString(v)
+}
+
+// Result of applying filters to a post
+type filtered struct {
+	hidden bool   // only set if hidden posts are shown
+	pinned bool   // sorted to the top
+	color  string // highlight
+}
+
+// If set, hidden posts are kept (and marked) instead of removed. Toggled from
+// the viewer.
+var showHidden atomic.Bool
+
+// Apply filter rules and manually hidden items to freshly fetched posts:
+// hidden posts are removed, pinned posts moved to the top, and the rest
+// marked. The OP of a thread is never hidden nor moved.
+func applyFilters(rules []FilterRule, hidden []hiddenEntry, posts []*Post, board string, catalog bool) []*Post {
+	var active []*FilterRule
+	for i := range rules {
+		if rules[i].applies(board, catalog) {
+			active = append(active, &rules[i])
+		}
+	}
+	if len(active) == 0 && len(hidden) == 0 {
+		return posts
+	}
+
+	for _, p := range posts {
+		p.filter = filtered{}
+	}
+	markHidden(hidden, posts, catalog)
+
+	var kept []*Post
+	for i, p := range posts {
+		for _, r := range active {
+			if !r.match(p) {
+				continue
+			}
+			switch r.Action {
+			case "hide":
+				p.filter.hidden = true
+			case "pin":
+				p.filter.pinned = true
+			case "highlight":
+				p.filter.color = r.Color
+			}
+		}
+		if !catalog && i == 0 {
+			p.filter = filtered{color: p.filter.color}
+		}
+		if p.filter.hidden && !showHidden.Load() {
+			continue
+		}
+		kept = append(kept, p)
+	}
+
+	start := 0
+	if !catalog {
+		start = 1
+	}
+	if len(kept) > start {
+		slices.SortStableFunc(kept[start:], func(a, b *Post) int {
+			return pinnedFirst(a, b)
+		})
+	}
+	return kept
+}
+
+// Apply filters and manually hidden items to freshly fetched posts of a board,
+// before showing them. Fetching does not filter, so that ibb dl and ibb watch
+// see all posts.
+func filterPosts(board string, posts []*Post, catalog bool) []*Post {
+	return applyFilters(config.Filters, state.hiddenOn(board), posts, board, catalog)
+}
+
+func pinnedFirst(a, b *Post) int {
+	switch {
+	case a.filter.pinned == b.filter.pinned:
+		return 0
+	case a.filter.pinned:
+		return -1
+	default:
+		return 1
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterRuleCompile(t *testing.T) {
+	for _, r := range []FilterRule{
+		{Field: "foo", Pattern: "x", Action: "hide"},
+		{Field: "name", Pattern: "(", Action: "hide"},
+		{Field: "name", Pattern: "x", Action: "delete"},
+		{Field: "name", Pattern: "x", Action: "highlight"}, // no color
+		{Field: "name", Pattern: "x", Action: "hide", Scope: "board"},
+	} {
+		assert.Error(t, r.compile(), r)
+	}
+	r := FilterRule{Field: "comment", Pattern: "(?i)spam", Action: "hide"}
+	assert.NoError(t, r.compile())
+}
+
+func TestApplyFilters(t *testing.T) {
+	rules := []FilterRule{
+		{Field: "comment", Pattern: "(?i)buy", Action: "hide"},
+		{Field: "trip", Pattern: "!abc", Exact: true, Action: "pin"},
+		{Field: "name", Pattern: "^moot$", Action: "highlight", Color: "1"},
+		{Field: "md5", Pattern: "xyz==", Exact: true, Action: "hide", Boards: []string{"v"}},
+		{Field: "country", Pattern: "US", Exact: true, Action: "hide", Scope: "catalog"},
+	}
+	for i := range rules {
+		assert.NoError(t, rules[i].compile())
+	}
+
+	posts := func() []*Post {
+		return []*Post{
+			{Num: 1, Comment: "BUY now", Name: "moot"}, // OP
+			{Num: 2, Comment: "buy<br>this"},
+			{Num: 3, Trip: "!abc"},
+			{Num: 4, MD5: "xyz=="},
+			{Num: 5, Country: "US", Name: "moot"},
+		}
+	}
+	nums := func(posts []*Post) (n []int) {
+		for _, p := range posts {
+			n = append(n, p.Num)
+		}
+		return n
+	}
+
+	// thread: OP kept in place, md5 rule only on /v/, country rule only
+	// in catalog
+	got := applyFilters(rules, nil, posts(), "g", false)
+	assert.Equal(t, []int{1, 3, 4, 5}, nums(got))
+	assert.Equal(t, "1", got[0].filter.color)
+	assert.True(t, got[1].filter.pinned)
+	assert.Equal(t, "1", got[3].filter.color)
+
+	// pinned posts move to the top, so the newest post need not be last
+	got = applyFilters(rules, nil, append(posts(), &Post{Num: 6, Trip: "!abc"}), "g", false)
+	assert.Equal(t, []int{1, 3, 6, 4, 5}, nums(got))
+	assert.Equal(t, 6, (&Thread{Posts: got}).newest())
+
+	got = applyFilters(rules, nil, posts(), "v", true)
+	assert.Equal(t, []int{3}, nums(got))
+
+	showHidden.Store(true)
+	defer showHidden.Store(false)
+	got = applyFilters(rules, nil, posts(), "v", true)
+	assert.Equal(t, []int{3, 1, 2, 4, 5}, nums(got))
+	assert.True(t, got[1].filter.hidden)
+	assert.False(t, got[0].filter.hidden)
+}
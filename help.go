@@ -0,0 +1,54 @@
+// Help: all actions and their keys, generated from the action table
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Contexts that help is grouped by
+var helpGroups = []struct {
+	name string
+	mode mode
+}{
+	{"catalog", catalogMode},
+	{"thread", threadMode},
+	{"search (while typing)", searchMode},
+	{"lists (watchlist, history, hidden, tabs, help)", panelMode},
+	{"board list", boardsMode},
+}
+
+func (m *ThreadViewer) showHelp() tea.Cmd {
+	if m.panel == helpPanel {
+		return m.closePanel()
+	}
+	m.helpMode = m.mode()
+	return m.togglePanel(helpPanel)
+}
+
+// Returns lines of the help panel, and whether each line is about an action
+// that is unavailable where help was opened from
+func (m *ThreadViewer) helpItems() (items []string, dim []bool) {
+	for _, g := range helpGroups {
+		items = append(items, fmt.Sprintf("-- %s --", g.name))
+		dim = append(dim, g.mode != m.helpMode)
+		for _, a := range actions {
+			if a.modes&g.mode == 0 {
+				continue
+			}
+			var keys []string
+			for _, k := range config.keymap[a] {
+				keys = append(keys, keyName(k))
+			}
+			if len(keys) == 0 {
+				keys = []string{"(unbound)"}
+			}
+			items = append(items, fmt.Sprintf("  %-14s %-16s %s", strings.Join(keys, " "), a.name, a.help))
+			dim = append(dim, a.modes&m.helpMode == 0)
+		}
+	}
+	return items, dim
+}
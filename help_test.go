@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHelpItems(t *testing.T) {
+	km, err := newKeymap(map[string]keyList{"yank": {"c"}, "play": {}})
+	require.NoError(t, err)
+	defer func(km keymap) { config.keymap = km }(config.keymap)
+	config.keymap = km
+
+	m := ThreadViewer{helpMode: catalogMode}
+	items, dim := m.helpItems()
+	require.Len(t, dim, len(items))
+
+	find := func(prefix string) (int, bool) {
+		for i, item := range items {
+			if len(item) >= len(prefix) && item[:len(prefix)] == prefix {
+				return i, true
+			}
+		}
+		return 0, false
+	}
+
+	i, ok := find("  c              yank")
+	assert.True(t, ok)
+	assert.False(t, dim[i])
+
+	i, ok = find("  (unbound)      play")
+	assert.True(t, ok)
+	assert.True(t, dim[i]) // thread only
+
+	i, _ = find("-- catalog")
+	assert.False(t, dim[i])
+	i, _ = find("-- thread")
+	assert.True(t, dim[i])
+}
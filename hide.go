@@ -0,0 +1,182 @@
+// Manually hidden threads and posts, persisted per board until the thread
+// leaves the archive
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+)
+
+type hiddenEntry struct {
+	Board     string    `json:"board"`
+	Thread    int       `json:"thread"`
+	Post      int       `json:"post"`      // 0 if the whole thread is hidden
+	Recursive bool      `json:"recursive"` // also hide replies
+	Text      string    `json:"text"`      // for the hidden list
+	Hidden    time.Time `json:"hidden"`
+}
+
+func (e hiddenEntry) key() string {
+	if e.Post == 0 {
+		return threadKeyString(e.Board, e.Thread)
+	}
+	return fmt.Sprintf("%s/%d", threadKeyString(e.Board, e.Thread), e.Post)
+}
+
+func (e hiddenEntry) String() string {
+	s := fmt.Sprintf("/%s/%d", e.Board, e.Thread)
+	if e.Post != 0 {
+		s += fmt.Sprintf(" #%d", e.Post)
+	}
+	return s + " " + e.Text
+}
+
+// Hide a thread (if p is its OP) or a single post
+func newHiddenEntry(t *Thread, p *Post, catalog bool) hiddenEntry {
+	e := hiddenEntry{
+		Board:     p.Board,
+		Thread:    p.Num,
+		Recursive: config.Hide.Recursive,
+		Text:      p.Subject,
+		Hidden:    time.Now(),
+	}
+	if !catalog {
+		e.Thread = t.Posts[0].Num
+		if p.Num != e.Thread {
+			e.Post = p.Num
+		}
+	}
+	if e.Text == "" {
+		e.Text = p.lineComment()
+	}
+	return e
+}
+
+func (s *stateStore) hide(e hiddenEntry) {
+	s.put(hiddenBucket, e.key(), e)
+}
+
+func (s *stateStore) unhide(e hiddenEntry) {
+	s.delete(hiddenBucket, e.key())
+}
+
+// Returns all hidden items, most recently hidden first
+func (s *stateStore) hidden() (entries []hiddenEntry) {
+	s.each(hiddenBucket, func(_ string, data []byte) {
+		var e hiddenEntry
+		if json.Unmarshal(data, &e) == nil {
+			entries = append(entries, e)
+		}
+	})
+	slices.SortFunc(entries, func(a, b hiddenEntry) int { return b.Hidden.Compare(a.Hidden) })
+	return entries
+}
+
+// Returns hidden items of a board
+func (s *stateStore) hiddenOn(board string) (entries []hiddenEntry) {
+	for _, e := range s.hidden() {
+		if e.Board == board {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// Mark manually hidden posts. In a catalog, whole threads are hidden; in a
+// thread, single posts (and, if recursive, replies to them).
+func markHidden(hidden []hiddenEntry, posts []*Post, catalog bool) {
+	if len(hidden) == 0 || len(posts) == 0 {
+		return
+	}
+
+	if catalog {
+		threads := map[int]bool{}
+		for _, e := range hidden {
+			if e.Post == 0 {
+				threads[e.Thread] = true
+			}
+		}
+		for _, p := range posts {
+			if threads[p.Num] {
+				p.filter.hidden = true
+			}
+		}
+		return
+	}
+
+	op := posts[0].Num
+	direct := map[int]bool{}
+	recursive := map[int]bool{} // grows as replies are found
+	for _, e := range hidden {
+		if e.Thread != op || e.Post == 0 {
+			continue
+		}
+		direct[e.Post] = true
+		if e.Recursive {
+			recursive[e.Post] = true
+		}
+	}
+	for _, p := range posts[1:] {
+		reply := slices.ContainsFunc(p.quotes(), func(id int) bool { return recursive[id] })
+		if reply {
+			recursive[p.Num] = true
+		}
+		if direct[p.Num] || reply {
+			p.filter.hidden = true
+		}
+	}
+}
+
+// Returns numbers of all threads in the archive of a board. Boards without
+// an archive return none.
+func getArchive(board string) ([]int, error) {
+	b, err := getJSON(fmt.Sprintf("https://a.4cdn.org/%s/archive.json", board))
+	switch {
+	case errors.Is(err, errNotFound):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+	var ids []int
+	err = json.Unmarshal(b, &ids)
+	return ids, err
+}
+
+// Forget hidden items of threads that are neither in the (unfiltered)
+// catalog nor in the archive of their board
+func (s *stateStore) gcHidden(board string, catalog []*Post) {
+	hidden := s.hiddenOn(board)
+	if len(hidden) == 0 {
+		return
+	}
+
+	alive := map[int]bool{}
+	for _, p := range catalog {
+		alive[p.Num] = true
+	}
+	archived, err := getArchive(board)
+	if err != nil {
+		log.Println("gc hidden:", board, err)
+		return
+	}
+	for _, id := range archived {
+		alive[id] = true
+	}
+
+	var dead []string
+	for _, e := range hidden {
+		if !alive[e.Thread] {
+			dead = append(dead, e.key())
+		}
+	}
+	if len(dead) > 0 {
+		log.Println("gc hidden:", strings.Join(dead, " "))
+		s.delete(hiddenBucket, dead...)
+	}
+}
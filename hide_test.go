@@ -0,0 +1,40 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHidden(t *testing.T) {
+	s := &stateStore{path: filepath.Join(t.TempDir(), "state.db")}
+	assert.Empty(t, s.hidden())
+
+	thread := hiddenEntry{Board: "g", Thread: 1}
+	post := hiddenEntry{Board: "g", Thread: 2, Post: 3, Recursive: true}
+	other := hiddenEntry{Board: "v", Thread: 4}
+	for _, e := range []hiddenEntry{thread, post, other} {
+		s.hide(e)
+	}
+	assert.Len(t, s.hidden(), 3)
+	assert.Len(t, s.hiddenOn("g"), 2)
+
+	catalog := []*Post{{Num: 1}, {Num: 2}, {Num: 4}}
+	kept := applyFilters(nil, s.hiddenOn("g"), catalog, "g", true)
+	assert.Equal(t, []*Post{catalog[1], catalog[2]}, kept)
+
+	posts := []*Post{
+		{Num: 2, Comment: "op"},
+		{Num: 3, Comment: "hidden"},
+		{Num: 5, Comment: "&gt;&gt;3"},           // reply to hidden
+		{Num: 6, Comment: "&gt;&gt;5"},           // reply to reply
+		{Num: 7, Comment: "&gt;&gt;2"},           // reply to OP
+		{Num: 8, Comment: "&gt;&gt;7 &gt;&gt;2"}, // not hidden
+	}
+	kept = applyFilters(nil, s.hiddenOn("g"), posts, "g", false)
+	assert.Equal(t, []*Post{posts[0], posts[4], posts[5]}, kept)
+
+	s.unhide(post)
+	assert.Len(t, s.hiddenOn("g"), 1)
+}
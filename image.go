@@ -6,14 +6,18 @@
 package main
 
 import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"image"
 	"image/jpeg"
 	"image/png"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
-	"strings"
 
 	"github.com/dolmen-go/kittyimg" // faster than mattn/go-sixel
 	"github.com/nfnt/resize"
@@ -22,6 +26,7 @@ import (
 type Size struct {
 	width  int // in chars
 	height int // in chars
+	x, y   int // position in chars, from the top left; only used by Render
 }
 
 const (
@@ -59,17 +64,69 @@ func decode(fname string) (img image.Image, ierr error) {
 	return img, ierr
 }
 
-// Render a single image to stdout. Constraining the image to a given size is
-// recommended, as rendering time scales quadratically with image size.
+// Only the kitty protocol is currently supported; this is part of the render
+// cache key nonetheless
+const protocol = "kitty"
+
+// Render a single image to stdout, within the given area of the screen (if
+// any). Constraining the image to a given size is recommended, as rendering
+// time scales quadratically with image size.
 func Render(fname string, size *Size) {
+	var buf bytes.Buffer
+	if size != nil { // save cursor, so that the tea.Program does not lose track of it
+		fmt.Fprintf(&buf, "\x1b7\x1b[%d;%dH", size.y+1, size.x+1)
+	}
+	renderTo(&buf, fname, size)
+	if size != nil {
+		buf.WriteString("\x1b8")
+	}
+	_, _ = os.Stdout.Write(buf.Bytes())
+}
+
+// Like Render, but rendered output is cached (keyed by image, size and
+// protocol), so that displaying the same image again is instant.
+func renderTo(w io.Writer, fname string, size *Size) {
+	key := renderKey(fname, size)
+	b, ok := renderCache.get(key)
+	if !ok {
+		var err error
+		b, err = encode(fname, size)
+		if err != nil {
+			log.Println("failed to render", fname, err)
+			return
+		}
+		renderCache.put(key, b)
+	}
+
+	if _, err := w.Write(b); err != nil {
+		panic(err)
+	}
+}
+
+// Images are identified by filename, which for 4chan is the (unique) upload
+// timestamp
+func renderKey(fname string, size *Size) string {
+	var w, h int
+	if size != nil {
+		w, h = size.width*CharWidthPx, size.height*CharHeightPx
+	}
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s-%dx%d-%s", filepath.Base(fname), w, h, protocol)))
+	return hex.EncodeToString(sum[:])
+}
+
+// Decode, resize and encode an image, including padding
+func encode(fname string, size *Size) ([]byte, error) {
 	img, err := decode(fname)
 	if err != nil {
-		return
+		return nil, err
 	}
 
 	if img == nil {
-		return
+		return nil, errors.New("unsupported format")
 	}
+
+	var buf bytes.Buffer
+
 	// img size in pixels
 	imgX := img.Bounds().Max.X
 	imgY := img.Bounds().Max.Y
@@ -81,13 +138,14 @@ func Render(fname string, size *Size) {
 
 		log.Println("img dims:", imgX, imgY)
 
-		// always resize by height, preserving aspect ratio
-		// note that size refers to the entire screen, so we /2 to
-		// account for upper pane. this should eventually be the
-		// responsibility of caller
+		// fit to the area, preserving aspect ratio
+		scale := min(
+			float64(size.width*CharWidthPx)/float64(imgX),
+			float64(size.height*CharHeightPx)/float64(imgY),
+		)
 		img = resize.Resize(
-			0,
-			uint(CharHeightPx*55), // 55 is magic, apparently
+			uint(float64(imgX)*scale),
+			uint(float64(imgY)*scale),
 			img,
 			interp,
 		)
@@ -97,12 +155,15 @@ func Render(fname string, size *Size) {
 
 		log.Println("resized dims:", imgX, imgY)
 
-		xPad := (size.width - (imgX / CharHeightPx)) / 2
-		yPad := (size.height - (imgY / CharWidthPx)) / 2
-		// log.Println("yPad", yPad)
-
-		fmt.Println(strings.Repeat("\n", max(0, yPad)))
-		fmt.Println(strings.Repeat(" ", xPad))
+		// center within the area, by moving the cursor down and right
+		xPad := (size.width - imgX/CharWidthPx) / 2
+		yPad := (size.height - imgY/CharHeightPx) / 2
+		if yPad > 0 {
+			fmt.Fprintf(&buf, "\x1b[%dB", yPad)
+		}
+		if xPad > 0 {
+			fmt.Fprintf(&buf, "\x1b[%dC", xPad)
+		}
 
 	}
 
@@ -110,8 +171,8 @@ func Render(fname string, size *Size) {
 	// 	panic(err)
 	// }
 
-	if err := kittyimg.Fprintln(os.Stdout, img); err != nil {
-		panic(err)
+	if err := kittyimg.Fprintln(&buf, img); err != nil {
+		return nil, err
 	}
 
 	// the result is a b64-encoded string; s and v refer to width and
@@ -121,4 +182,6 @@ func Render(fname string, size *Size) {
 	//
 	// https://sw.kovidgoyal.net/kitty/graphics-protocol/#the-graphics-escape-code
 	// https://github.com/benjajaja/ratatui-image/blob/afbdd4e79251ef0709e4a2d9281b3ac6eb73291a/src/protocol/kitty.rs#L150
+
+	return buf.Bytes(), nil
 }
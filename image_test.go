@@ -0,0 +1,69 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Write a 4000x3000 jpg, roughly the size of a typical phone photo
+func largeImage(b *testing.B) string {
+	img := image.NewRGBA(image.Rect(0, 0, 4000, 3000))
+	for x := range 4000 {
+		for y := range 3000 {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), uint8(x + y), 255})
+		}
+	}
+
+	fname := filepath.Join(b.TempDir(), "1234567890.jpg")
+	f, err := os.Create(fname)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+	if err := jpeg.Encode(f, img, nil); err != nil {
+		b.Fatal(err)
+	}
+	return fname
+}
+
+var benchSize = &Size{width: 200, height: 60}
+
+func BenchmarkRenderUncached(b *testing.B) {
+	fname := largeImage(b)
+	b.ResetTimer()
+	for range b.N {
+		if _, err := encode(fname, benchSize); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRenderMemCached(b *testing.B) {
+	fname := largeImage(b)
+	defer func(c *lruCache) { renderCache = c }(renderCache)
+	renderCache = newLRUCache(64, nil)
+
+	renderTo(io.Discard, fname, benchSize)
+	b.ResetTimer()
+	for range b.N {
+		renderTo(io.Discard, fname, benchSize)
+	}
+}
+
+func BenchmarkRenderDiskCached(b *testing.B) {
+	fname := largeImage(b)
+	defer func(c *lruCache) { renderCache = c }(renderCache)
+	// nothing is kept in memory
+	renderCache = newLRUCache(0, &diskCache{dir: b.TempDir()})
+
+	renderTo(io.Discard, fname, benchSize)
+	b.ResetTimer()
+	for range b.N {
+		renderTo(io.Discard, fname, benchSize)
+	}
+}
@@ -0,0 +1,379 @@
+// Key bindings: every action of the viewer, with its default keys. Keys may
+// be remapped in the config. A binding may be a sequence of keys separated by
+// spaces, e.g. "g t".
+
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"gopkg.in/yaml.v3"
+)
+
+// Where an action is available
+type mode int
+
+const (
+	catalogMode mode = 1 << iota
+	threadMode
+	searchMode // typing a search query
+	panelMode
+	boardsMode // board list
+)
+
+const (
+	viewMode = catalogMode | threadMode
+	listMode = panelMode | boardsMode // any panel
+)
+
+var modes = []mode{catalogMode, threadMode, searchMode, panelMode, boardsMode}
+
+func (md mode) String() string {
+	var names []string
+	for _, m := range modes {
+		if md&m == 0 {
+			continue
+		}
+		switch m {
+		case catalogMode:
+			names = append(names, "catalog")
+		case threadMode:
+			names = append(names, "thread")
+		case searchMode:
+			names = append(names, "search")
+		case panelMode:
+			names = append(names, "panel")
+		case boardsMode:
+			names = append(names, "board list")
+		}
+	}
+	return strings.Join(names, "/")
+}
+
+func (m *ThreadViewer) mode() mode {
+	switch {
+	case m.searching, m.typingQuery:
+		return searchMode
+	case m.panel == boardsPanel:
+		return boardsMode
+	case m.panel != noPanel:
+		return panelMode
+	case m.catalog:
+		return catalogMode
+	default:
+		return threadMode
+	}
+}
+
+type action struct {
+	name  string // as used in the config
+	help  string
+	modes mode
+	keys  []string // default bindings; see keySeq
+	// Redraw the image after running
+	redraw bool
+	// Run with the next key as argument (ThreadViewer.arg), e.g. ma
+	arg bool
+	// Nil for actions only handled by panels or search
+	run func(m *ThreadViewer) tea.Cmd
+}
+
+var actions = []*action{
+	// movement
+	{name: "down", help: "next post", modes: viewMode | listMode, keys: []string{"j"}, redraw: true,
+		run: func(m *ThreadViewer) tea.Cmd { m.move(1); return nil }},
+	{name: "up", help: "previous post", modes: viewMode | listMode, keys: []string{"k"}, redraw: true,
+		run: func(m *ThreadViewer) tea.Cmd { m.move(-1); return nil }},
+	{name: "page-down", help: "move down a page", modes: viewMode, keys: []string{"pgdown"}, redraw: true,
+		run: func(m *ThreadViewer) tea.Cmd { m.move(m.pageDist()); return nil }},
+	{name: "page-up", help: "move up a page", modes: viewMode, keys: []string{"pgup"}, redraw: true,
+		run: func(m *ThreadViewer) tea.Cmd { m.move(-m.pageDist()); return nil }},
+	{name: "top", help: "first post (or post N, with a count)", modes: viewMode | listMode, keys: []string{"g g"}, redraw: true,
+		run: (*ThreadViewer).top},
+	{name: "bottom", help: "last post", modes: viewMode | listMode, keys: []string{"G"}, redraw: true,
+		run: func(m *ThreadViewer) tea.Cmd {
+			m.cursor = len(m.thread.Posts) - 1
+			m.moveCount = 0
+			return nil
+		}},
+	{name: "half-page-down", help: "scroll down half a page", modes: viewMode, keys: []string{"ctrl+d"}, redraw: true,
+		run: func(m *ThreadViewer) tea.Cmd { m.halfPage(1); return nil }},
+	{name: "half-page-up", help: "scroll up half a page", modes: viewMode, keys: []string{"ctrl+u"}, redraw: true,
+		run: func(m *ThreadViewer) tea.Cmd { m.halfPage(-1); return nil }},
+	{name: "screen-top", help: "top of the list (or Nth post from the top)", modes: viewMode, keys: []string{"H"}, redraw: true,
+		run: func(m *ThreadViewer) tea.Cmd { m.screenLine('H'); return nil }},
+	{name: "screen-middle", help: "middle of the list", modes: viewMode, keys: []string{"M"}, redraw: true,
+		run: func(m *ThreadViewer) tea.Cmd { m.screenLine('M'); return nil }},
+	{name: "screen-bottom", help: "bottom of the list (or Nth post from the bottom)", modes: viewMode, keys: []string{"L"}, redraw: true,
+		run: func(m *ThreadViewer) tea.Cmd { m.screenLine('L'); return nil }},
+	{name: "scroll", help: "scroll list: zz center, zt top, zb bottom", modes: viewMode, keys: []string{"z"}, arg: true,
+		run: (*ThreadViewer).recenter},
+	{name: "mark", help: "set mark, e.g. ma", modes: threadMode, keys: []string{"m"}, arg: true,
+		run: (*ThreadViewer).setMark},
+	{name: "jump-mark", help: "jump to mark, e.g. 'a", modes: threadMode, keys: []string{"'"}, arg: true, redraw: true,
+		run: (*ThreadViewer).jumpMark},
+
+	// navigation
+	{name: "open", help: "open thread", modes: catalogMode | listMode, keys: []string{"enter"}, redraw: true,
+		run: (*ThreadViewer).open},
+	{name: "back", help: "back to catalog (or search results)", modes: viewMode, keys: []string{"h"}, redraw: true,
+		run: (*ThreadViewer).back},
+	{name: "next-unread", help: "next unread post", modes: threadMode, keys: []string{"u"}, redraw: true,
+		run: func(m *ThreadViewer) tea.Cmd { m.nextUnread(); return nil }},
+	{name: "mark-read", help: "mark all posts read", modes: threadMode, keys: []string{"U"},
+		run: func(m *ThreadViewer) tea.Cmd { m.markAllRead(); return nil }},
+	{name: "quote", help: "go to quoted post (or the Nth quote)", modes: threadMode, keys: []string{">"}, redraw: true,
+		run: (*ThreadViewer).followQuote},
+	{name: "quote-back", help: "go back from quote or mark", modes: threadMode, keys: []string{"<"}, redraw: true,
+		run: (*ThreadViewer).quoteBack},
+
+	// buffers
+	{name: "next-buffer", help: "next tab (or tab N, with a count)", modes: viewMode, keys: []string{"g t"}, redraw: true,
+		run: func(m *ThreadViewer) tea.Cmd { return m.cycleBuffer(1) }},
+	{name: "prev-buffer", help: "previous tab", modes: viewMode, keys: []string{"g T"}, redraw: true,
+		run: func(m *ThreadViewer) tea.Cmd { return m.cycleBuffer(-1) }},
+	{name: "close-buffer", help: "close tab, or quit if it is the last", modes: viewMode, keys: []string{"ctrl+w"}, redraw: true,
+		run: func(m *ThreadViewer) tea.Cmd { return m.closeBuffer(m.buffer) }},
+
+	// search
+	{name: "search", help: "search posts", modes: viewMode, keys: []string{"/"},
+		run: func(m *ThreadViewer) tea.Cmd {
+			m.searching = true
+			m.searchFrom = m.cursor
+			m.clearSearch()
+			return nil
+		}},
+	{name: "next-match", help: "next match", modes: viewMode, keys: []string{"n"}, redraw: true,
+		run: func(m *ThreadViewer) tea.Cmd {
+			for range m.count() {
+				m.nextMatch(1)
+			}
+			return nil
+		}},
+	{name: "prev-match", help: "previous match", modes: viewMode, keys: []string{"N"}, redraw: true,
+		run: func(m *ThreadViewer) tea.Cmd {
+			for range m.count() {
+				m.nextMatch(-1)
+			}
+			return nil
+		}},
+	{name: "search-boards", help: "search all boards for the current query, or the selected boards (board list)", modes: viewMode | boardsMode, keys: []string{"S"},
+		run: func(m *ThreadViewer) tea.Cmd {
+			if m.input == "" {
+				m.status = "nothing to search for; use / first"
+				return nil
+			}
+			return m.searchBoards(m.input, nil)
+		}},
+	{name: "select-board", help: "select board to search", modes: boardsMode, keys: []string{" "}},
+	{name: "search-accept", help: "finish search", modes: searchMode, keys: []string{"enter"}},
+	{name: "search-cancel", help: "cancel search", modes: searchMode, keys: []string{"esc"}},
+
+	// posts
+	{name: "toggle-comment", help: "toggle image/comment", modes: viewMode, keys: []string{" "}, redraw: true,
+		run: (*ThreadViewer).toggleComment},
+	{name: "toggle-both", help: "show image and comment together", modes: viewMode, keys: []string{"i"}, redraw: true,
+		run: (*ThreadViewer).toggleBoth},
+	{name: "comment-down", help: "scroll comment down", modes: viewMode, keys: []string{"ctrl+e", "J"},
+		run: func(m *ThreadViewer) tea.Cmd { return m.scrollComment(1) }},
+	{name: "comment-up", help: "scroll comment up", modes: viewMode, keys: []string{"ctrl+y", "K"},
+		run: func(m *ThreadViewer) tea.Cmd { return m.scrollComment(-1) }},
+	{name: "comment-page-down", help: "scroll comment down a page", modes: viewMode, keys: []string{"ctrl+f"},
+		run: func(m *ThreadViewer) tea.Cmd { return m.scrollComment(m.comment.Height) }},
+	{name: "comment-page-up", help: "scroll comment up a page", modes: viewMode, keys: []string{"ctrl+b"},
+		run: func(m *ThreadViewer) tea.Cmd { return m.scrollComment(-m.comment.Height) }},
+	{name: "save", help: "save image, and advance", modes: viewMode, keys: []string{"s"}, redraw: true,
+		run: (*ThreadViewer).save},
+	{name: "download", help: "save all images in thread", modes: threadMode, keys: []string{"D"},
+		run: func(m *ThreadViewer) tea.Cmd {
+			m.status = "downloading thread..."
+			return m.downloadAll()
+		}},
+	{name: "yank", help: "copy image url", modes: viewMode, keys: []string{"y"},
+		run: (*ThreadViewer).yank},
+	{name: "play", help: "play YouTube links in mpv", modes: threadMode, keys: []string{"p"},
+		run: (*ThreadViewer).play},
+	{name: "you", help: "mark post as (You)", modes: threadMode, keys: []string{"Y"},
+		run: func(m *ThreadViewer) tea.Cmd {
+			watched.toggleYou(&m.thread, m.currentPost().Num)
+			return nil
+		}},
+	{name: "hide", help: "hide thread or post", modes: viewMode, keys: []string{"x"}, redraw: true,
+		run: (*ThreadViewer).hide},
+	{name: "toggle-filtered", help: "show/hide filtered posts", modes: viewMode, keys: []string{"F"}, redraw: true,
+		run: func(m *ThreadViewer) tea.Cmd {
+			showHidden.Store(!showHidden.Load())
+			return m.reload()
+		}},
+	{name: "sort", help: "cycle sort order", modes: catalogMode, keys: []string{"o"}, redraw: true,
+		run: (*ThreadViewer).cycleSort},
+	{name: "watch", help: "watch/unwatch thread", modes: viewMode, keys: []string{"w"},
+		run: (*ThreadViewer).watch},
+	{name: "reload", help: "reload", modes: viewMode, keys: []string{"r"}, redraw: true,
+		run: (*ThreadViewer).reload},
+	{name: "split-grow", help: "grow the list pane", modes: viewMode, keys: []string{"+"}, redraw: true,
+		run: func(m *ThreadViewer) tea.Cmd { return m.moveSplit(splitStep) }},
+	{name: "split-shrink", help: "shrink the list pane", modes: viewMode, keys: []string{"-"}, redraw: true,
+		run: func(m *ThreadViewer) tea.Cmd { return m.moveSplit(-splitStep) }},
+	{name: "layout", help: "cycle layout: auto, stacked, side by side", modes: viewMode, keys: []string{"|"}, redraw: true,
+		run: (*ThreadViewer).cycleArrangement},
+	{name: "redraw", help: "redraw screen", modes: viewMode, keys: []string{"ctrl+l"}, redraw: true,
+		run: func(m *ThreadViewer) tea.Cmd { return nil }},
+
+	// panels
+	{name: "watchlist", help: "show watched threads", modes: viewMode | listMode, keys: []string{"W"},
+		run: func(m *ThreadViewer) tea.Cmd { return m.togglePanel(watchlistPanel) }},
+	{name: "history", help: "show recently visited threads", modes: viewMode | listMode, keys: []string{"ctrl+o"},
+		run: func(m *ThreadViewer) tea.Cmd { return m.togglePanel(historyPanel) }},
+	{name: "hidden", help: "show hidden threads and posts", modes: viewMode | listMode, keys: []string{"X"},
+		run: func(m *ThreadViewer) tea.Cmd { return m.togglePanel(hiddenPanel) }},
+	{name: "buffers", help: "show open tabs", modes: viewMode | listMode, keys: []string{"B"},
+		run: func(m *ThreadViewer) tea.Cmd { return m.togglePanel(buffersPanel) }},
+	{name: "boards", help: "show the board list", modes: viewMode | listMode, keys: []string{"b"},
+		run: (*ThreadViewer).showBoards},
+	{name: "help", help: "show this help", modes: viewMode | listMode, keys: []string{"?"},
+		run: (*ThreadViewer).showHelp},
+	{name: "remove", help: "unwatch/unhide item, or close tab", modes: panelMode, keys: []string{"d"}},
+	{name: "close", help: "close panel", modes: listMode, keys: []string{"q", "esc"}},
+
+	// quitting
+	{name: "cancel", help: "clear search, or quit", modes: viewMode, keys: []string{"esc"},
+		run: func(m *ThreadViewer) tea.Cmd {
+			if m.input != "" {
+				m.clearSearch()
+				return m.updateScreen()
+			}
+			return m.quit()
+		}},
+	{name: "quit", help: "quit", modes: viewMode, keys: []string{"q"},
+		run: (*ThreadViewer).quit},
+}
+
+func findAction(name string) *action {
+	i := slices.IndexFunc(actions, func(a *action) bool { return a.name == name })
+	if i < 0 {
+		return nil
+	}
+	return actions[i]
+}
+
+// Keys bound to each action
+type keymap map[*action][]string
+
+// Apply remapped keys from the config to the defaults. Keys bound to
+// different actions in the same mode are an error.
+func newKeymap(remap map[string]keyList) (keymap, error) {
+	km := keymap{}
+	for _, a := range actions {
+		km[a] = a.keys
+	}
+	for name, keys := range remap {
+		a := findAction(name)
+		if a == nil {
+			return nil, fmt.Errorf("unknown action %q", name)
+		}
+		for _, k := range keys {
+			switch {
+			case k == "":
+				return nil, fmt.Errorf("%s: empty key", name)
+			case len(k) == 1 && k[0] >= '0' && k[0] <= '9':
+				return nil, fmt.Errorf("%s: %q is reserved for counts", name, k)
+			}
+		}
+		km[a] = keys
+	}
+
+	for _, a := range actions {
+		for _, b := range actions {
+			if a == b || a.modes&b.modes == 0 || a.name > b.name {
+				continue
+			}
+			for _, k := range km[a] {
+				for _, l := range km[b] {
+					switch {
+					case k == l:
+						return nil, fmt.Errorf("%q is bound to both %s and %s", keyName(k), a.name, b.name)
+					case isPrefix(k, l) || isPrefix(l, k):
+						return nil, fmt.Errorf("%q (%s) and %q (%s) overlap", keyName(k), a.name, keyName(l), b.name)
+					}
+				}
+			}
+		}
+	}
+	return km, nil
+}
+
+// Returns the action bound to a key in the given mode. If there is none, an
+// action bound to the key in another mode is returned, if any.
+func (km keymap) lookup(key string, md mode) (a *action, ok bool) {
+	for _, a := range actions {
+		if a.modes&md != 0 && slices.Contains(km[a], key) {
+			return a, true
+		}
+	}
+	for _, a := range actions {
+		if slices.Contains(km[a], key) {
+			return a, false
+		}
+	}
+	return nil, false
+}
+
+// Whether keys are the start of a key sequence bound in the given mode
+func (km keymap) isPrefix(keys string, md mode) bool {
+	for _, a := range actions {
+		if a.modes&md == 0 {
+			continue
+		}
+		for _, k := range km[a] {
+			if isPrefix(keys, k) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Whether sequence seq starts with the keys of prefix (but is longer)
+func isPrefix(prefix, seq string) bool {
+	return prefix != " " && strings.HasPrefix(seq, prefix+" ")
+}
+
+// One or more keys, as named by bubbletea (e.g. "ctrl+o", "pgdown", "space")
+type keyList []string
+
+func (kl *keyList) UnmarshalYAML(n *yaml.Node) error {
+	var keys []string
+	switch n.Kind {
+	case yaml.ScalarNode:
+		keys = []string{n.Value}
+	default:
+		if err := n.Decode(&keys); err != nil {
+			return err
+		}
+	}
+	for i, k := range keys {
+		if k == "space" {
+			keys[i] = " "
+		}
+	}
+	*kl = keys
+	return nil
+}
+
+// e.g. "space", "gT", "ctrl+w g"
+func keyName(k string) string {
+	if k == " " {
+		return "space"
+	}
+	keys := strings.Fields(k)
+	for _, key := range keys {
+		if len(key) > 1 {
+			return k
+		}
+	}
+	return strings.Join(keys, "")
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeymap(t *testing.T) {
+	cfg := defaultConfig()
+	require.NoError(t, decodeConfig(strings.NewReader(`
+keys:
+  save: [S, space]
+  search-boards: ctrl+s
+  toggle-comment: c
+`), &cfg))
+
+	km := cfg.keymap
+	a, ok := km.lookup(" ", threadMode)
+	assert.True(t, ok)
+	assert.Equal(t, "save", a.name)
+	_, ok = km.lookup("s", threadMode) // no longer bound
+	assert.False(t, ok)
+
+	// same key, different modes
+	a, _ = km.lookup("q", panelMode)
+	assert.Equal(t, "close", a.name)
+	a, _ = km.lookup("q", catalogMode)
+	assert.Equal(t, "quit", a.name)
+
+	// bound, but not here
+	a, ok = km.lookup("p", catalogMode)
+	assert.False(t, ok)
+	assert.Equal(t, "play", a.name)
+
+	// sequences
+	assert.True(t, km.isPrefix("g", catalogMode))
+	assert.False(t, km.isPrefix("g t", catalogMode))
+	a, ok = km.lookup("g T", catalogMode)
+	assert.True(t, ok)
+	assert.Equal(t, "prev-buffer", a.name)
+	assert.Equal(t, "gT", keyName("g T"))
+	assert.Equal(t, "ctrl+w g", keyName("ctrl+w g"))
+
+	for yml, want := range map[string]string{
+		"keys: {foo: x}":           `unknown action "foo"`,
+		"keys: {save: j}":          `"j" is bound to both down and save`,
+		"keys: {save: '5'}":        `reserved for counts`,
+		"keys: {save: [space, s]}": `"space" is bound to both save and toggle-comment`,
+		"keys: {save: g}":          `overlap`,
+	} {
+		cfg := defaultConfig()
+		err := decodeConfig(strings.NewReader(yml), &cfg)
+		if assert.Error(t, err, yml) {
+			assert.Contains(t, err.Error(), want)
+		}
+	}
+}
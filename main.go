@@ -6,6 +6,7 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"runtime"
@@ -14,8 +15,6 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 )
 
-const tmpDir = "/tmp/ibb"
-
 func main() {
 	// TODO: find memory leak (probably not .Close-ing something somewhere)
 
@@ -26,8 +25,35 @@ func main() {
 
 	log.Println("started")
 
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	config = cfg
+
 	var p *tea.Program
 
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "cache":
+			cacheCmd(os.Args[2:])
+			return
+		case "dl":
+			dlCmd(os.Args[2:])
+			return
+		case "watch":
+			watchCmd(os.Args[2:])
+			return
+		case "search":
+			searchCmd(os.Args[2:])
+			return
+		case "open":
+			openCmd(os.Args[2:])
+			return
+		}
+	}
+
 	switch len(os.Args) {
 	case 1:
 
@@ -41,21 +67,26 @@ func main() {
 		// padded). on the other hand, threads always render correctly!
 		// but on hr, catalog is fine, which suggests the error is
 		// specific to that rms image (lol)
-		board := os.Args[1]
-		c := getCatalog(board)
-		t := Thread(c)
-		// log.Println(c.Board, t.Board)
-		p = tea.NewProgram(
-			&ThreadViewer{thread: t, catalog: true},
-			tea.WithAltScreen(),
-		)
+
+		// board, or any thread/post reference (see parseRef)
+		r, err := parseRef(os.Args[1])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		m, err := r.viewer()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, r, err)
+			os.Exit(1)
+		}
+		p = tea.NewProgram(m, viewerOptions()...)
 
 	case 3:
 		board, subject := os.Args[1], os.Args[2]
 		t := getCatalog(board).findThread(subject)
 		p = tea.NewProgram(
-			&ThreadViewer{thread: *t, catalog: false},
-			tea.WithAltScreen(),
+			newViewer(&buffer{thread: *t, catalog: false}),
+			viewerOptions()...,
 		)
 
 	default:
@@ -0,0 +1,196 @@
+// Vim-like motions: counts, half pages, H/M/L, z-scrolling, marks and quote
+// jumps
+
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Count typed before the current action, or 1
+func (m *ThreadViewer) count() int {
+	return max(1, m.moveCount)
+}
+
+// Number of posts that fit in the posts list
+func (m *ThreadViewer) listRows() int {
+	switch {
+	case m.short:
+		return max(1, m.height-1) // below header
+	case m.side:
+		return max(1, m.height-3) // below header, within border
+	default:
+		return max(1, m.height*m.split/100)
+	}
+}
+
+// Minimum number of posts kept above and below the cursor
+func (m *ThreadViewer) scrolloff() int {
+	return min(m.listRows()/4, (m.listRows()-1)/2)
+}
+
+// Adjust the scroll offset to keep the cursor in view (at least scrolloff
+// posts from either edge), and return the range of posts shown. If the
+// cursor jumped out of view, it is centered.
+func (m *ThreadViewer) scrollWindow() (start, end int) {
+	rows, so, n := m.listRows(), m.scrolloff(), len(m.thread.Posts)
+	if m.cursor < m.scroll || m.cursor >= m.scroll+rows {
+		m.scroll = m.cursor - rows/2
+	}
+	m.scroll = max(m.cursor-rows+1+so, min(m.scroll, m.cursor-so))
+	m.scroll = max(0, min(m.scroll, n-rows))
+	return m.scroll, min(n, m.scroll+rows)
+}
+
+// Move the cursor and the list by half a page (times the count), like
+// ctrl+d/ctrl+u. Does not wrap around.
+func (m *ThreadViewer) halfPage(dir int) {
+	d := dir * m.count() * max(1, m.listRows()/2)
+	m.cursor = max(0, min(m.cursor+d, len(m.thread.Posts)-1))
+	m.scroll += d
+}
+
+// Move to the top (H), middle (M) or bottom (L) of the list, as last drawn.
+// With a count, H and L move to the Nth post from the top or bottom.
+func (m *ThreadViewer) screenLine(where rune) {
+	start, end := m.scrollWindow()
+	so := m.scrolloff()
+	switch where {
+	case 'H':
+		off := m.count() - 1
+		if start > 0 {
+			off = max(off, so)
+		}
+		m.cursor = min(start+off, end-1)
+	case 'M':
+		m.cursor = start + (end-start-1)/2
+	case 'L':
+		off := m.count() - 1
+		if end < len(m.thread.Posts) {
+			off = max(off, so)
+		}
+		m.cursor = max(end-1-off, start)
+	}
+}
+
+// Scroll the list so that the cursor is at the center (zz), top (zt) or
+// bottom (zb)
+func (m *ThreadViewer) recenter() tea.Cmd {
+	rows, so := m.listRows(), m.scrolloff()
+	switch m.arg {
+	case "z", ".":
+		m.scroll = m.cursor - rows/2
+	case "t", "enter":
+		m.scroll = m.cursor - so
+	case "b", "-":
+		m.scroll = m.cursor - rows + 1 + so
+	default:
+		m.status = fmt.Sprintf("z%s: use zz, zt or zb", keyName(m.arg))
+	}
+	return nil
+}
+
+func validMark(key string) bool {
+	return len(key) == 1 && (key[0] >= 'a' && key[0] <= 'z' || key[0] >= 'A' && key[0] <= 'Z')
+}
+
+// Set a mark (a-z, A-Z) on the current post, like ma
+func (m *ThreadViewer) setMark() tea.Cmd {
+	if !validMark(m.arg) {
+		m.status = fmt.Sprintf("invalid mark %q", keyName(m.arg))
+		return nil
+	}
+	if m.marks == nil {
+		m.marks = map[string]int{}
+	}
+	m.marks[m.arg] = m.currentPost().Num
+	m.status = "marked " + m.arg
+	return nil
+}
+
+// Jump to a mark set in this thread, like 'a
+func (m *ThreadViewer) jumpMark() tea.Cmd {
+	num, ok := m.marks[m.arg]
+	if !ok {
+		m.status = fmt.Sprintf("mark %s not set", keyName(m.arg))
+		return nil
+	}
+	idx, err := m.thread.getIndex(num)
+	if err != nil {
+		m.status = fmt.Sprintf("mark %s: post not found", m.arg)
+		return nil
+	}
+	m.jumpTo(idx)
+	return nil
+}
+
+// Move the cursor to posts[idx], remembering where it was for quoteBack
+func (m *ThreadViewer) jumpTo(idx int) {
+	if idx == m.cursor {
+		return
+	}
+	m.jumps = append(m.jumps, m.currentPost().Num)
+	m.cursor = idx
+}
+
+// Follow the first quotelink of the current post (or the Nth, with a count)
+func (m *ThreadViewer) followQuote() tea.Cmd {
+	var quotes []int
+	for _, id := range m.currentPost().quotes() {
+		if !slices.Contains(quotes, id) {
+			quotes = append(quotes, id)
+		}
+	}
+	switch {
+	case len(quotes) == 0:
+		m.status = "no quotes"
+		return nil
+	case m.count() > len(quotes):
+		m.status = "only " + strconv.Itoa(len(quotes)) + " quotes"
+		return nil
+	}
+	idx, err := m.thread.getIndex(quotes[m.count()-1])
+	if err != nil {
+		m.status = "post not found"
+		return nil
+	}
+	m.jumpTo(idx)
+	return nil
+}
+
+// Go back to where the last quote (or mark) jump started; with a count, that
+// many jumps back
+func (m *ThreadViewer) quoteBack() tea.Cmd {
+	for range m.count() {
+		if len(m.jumps) == 0 {
+			m.status = "no previous jump"
+			return nil
+		}
+		num := m.jumps[len(m.jumps)-1]
+		m.jumps = m.jumps[:len(m.jumps)-1]
+		if idx, err := m.thread.getIndex(num); err == nil {
+			m.cursor = idx
+		}
+	}
+	return nil
+}
+
+// Line number of posts[i] in the list, per config.LineNumbers; empty if off
+func (m *ThreadViewer) lineNumber(i int) string {
+	width := len(strconv.Itoa(len(m.thread.Posts)))
+	switch config.LineNumbers {
+	case "absolute":
+		return fmt.Sprintf("%*d ", width, i+1)
+	case "relative":
+		if i == m.cursor { // like vim's number+relativenumber
+			return fmt.Sprintf("%-*d ", width, i+1)
+		}
+		return fmt.Sprintf("%*d ", width, max(i-m.cursor, m.cursor-i))
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testViewer(n int) *ThreadViewer {
+	m := newViewer(&buffer{})
+	m.height, m.short = 21, true // 20 rows, scrolloff 5
+	for i := range n {
+		m.thread.Posts = append(m.thread.Posts, &Post{Num: 100 + i})
+	}
+	return m
+}
+
+func TestScrollWindow(t *testing.T) {
+	m := testViewer(100)
+	start, end := m.scrollWindow()
+	assert.Equal(t, []int{0, 20}, []int{start, end})
+
+	m.cursor = 14 // within scrolloff of the bottom edge
+	start, _ = m.scrollWindow()
+	assert.Equal(t, 0, start)
+	m.cursor = 15
+	start, _ = m.scrollWindow()
+	assert.Equal(t, 1, start)
+
+	m.cursor = 60 // out of view: centered
+	start, _ = m.scrollWindow()
+	assert.Equal(t, 50, start)
+
+	m.cursor = 99
+	start, end = m.scrollWindow()
+	assert.Equal(t, []int{80, 100}, []int{start, end})
+
+	m.cursor = 50
+	m.scrollWindow()
+	m.arg = "t"
+	m.recenter()
+	start, _ = m.scrollWindow()
+	assert.Equal(t, 45, start)
+	m.arg = "b"
+	m.recenter()
+	start, _ = m.scrollWindow()
+	assert.Equal(t, 36, start)
+	m.arg = "z"
+	m.recenter()
+	start, _ = m.scrollWindow()
+	assert.Equal(t, 40, start)
+}
+
+func TestMotions(t *testing.T) {
+	m := testViewer(100)
+	m.cursor = 50
+	m.scrollWindow() // 40-60
+
+	m.screenLine('H')
+	assert.Equal(t, 45, m.cursor)
+	m.screenLine('L')
+	assert.Equal(t, 54, m.cursor)
+	m.screenLine('M')
+	assert.Equal(t, 49, m.cursor)
+
+	m.halfPage(1)
+	assert.Equal(t, 59, m.cursor)
+	m.moveCount = 10
+	m.halfPage(1) // clamped
+	assert.Equal(t, 99, m.cursor)
+	m.halfPage(-1)
+	assert.Equal(t, 0, m.cursor)
+
+	m.moveCount = 3
+	m.screenLine('H') // at the top, scrolloff doesn't apply
+	assert.Equal(t, 2, m.cursor)
+	m.moveCount = 0
+}
+
+func TestMarksAndQuotes(t *testing.T) {
+	m := testViewer(10)
+	m.thread.Posts[5].Comment = `<a href="#p102" class="quotelink">&gt;&gt;102</a><br>` +
+		`<a href="#p107" class="quotelink">&gt;&gt;107</a>`
+
+	m.cursor = 5
+	m.arg = "a"
+	m.setMark()
+	m.arg = "1"
+	m.setMark()
+	assert.Equal(t, `invalid mark "1"`, m.status)
+
+	m.followQuote()
+	assert.Equal(t, 2, m.cursor)
+	m.quoteBack()
+	assert.Equal(t, 5, m.cursor)
+	m.moveCount = 2
+	m.followQuote()
+	assert.Equal(t, 7, m.cursor)
+	m.moveCount = 3
+	m.cursor = 5
+	m.followQuote()
+	assert.Equal(t, "only 2 quotes", m.status)
+	m.moveCount = 0
+
+	m.cursor = 0
+	m.arg = "a"
+	m.jumpMark()
+	assert.Equal(t, 5, m.cursor)
+	m.arg = "b"
+	m.jumpMark()
+	assert.Equal(t, "mark b not set", m.status)
+	m.quoteBack()
+	assert.Equal(t, 0, m.cursor)
+}
+
+func TestLineNumbers(t *testing.T) {
+	m := testViewer(12)
+	m.cursor = 3
+	defer func(s string) { config.LineNumbers = s }(config.LineNumbers)
+
+	config.LineNumbers = "none"
+	assert.Equal(t, "", m.lineNumber(0))
+	config.LineNumbers = "absolute"
+	assert.Equal(t, " 1 ", m.lineNumber(0))
+	assert.Equal(t, "11 ", m.lineNumber(10))
+	config.LineNumbers = "relative"
+	assert.Equal(t, " 3 ", m.lineNumber(0))
+	assert.Equal(t, "4  ", m.lineNumber(3))
+	assert.Equal(t, " 7 ", m.lineNumber(10))
+}
@@ -0,0 +1,124 @@
+// Mouse support: click to select posts and follow quotelinks, wheel to
+// scroll. Hit-testing uses the layout recorded by the last View.
+
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Screen positions of what View last drew
+type layout struct {
+	listTop     int      // screen line of the first row of the list
+	rows        []int    // index of the post (or panel item) in each row; -1 if none
+	commentTop  int      // screen line of the comment pane; -1 if not shown
+	paneLeft    int      // screen column of the image/comment pane, if right of the list
+	commentLeft int      // screen column of the comment pane
+	comment     []string // lines of the comment pane, as shown, without styles
+}
+
+// Returns index of the post (or panel item) at a screen position
+func (l layout) row(x, y int) (int, bool) {
+	i := y - l.listTop
+	if i < 0 || i >= len(l.rows) || l.rows[i] < 0 || l.paneLeft > 0 && x >= l.paneLeft {
+		return 0, false
+	}
+	return l.rows[i], true
+}
+
+func (l layout) inComment(x, y int) bool {
+	return l.commentTop >= 0 && y >= l.commentTop && x >= l.commentLeft
+}
+
+var quotelinkText = regexp.MustCompile(`>>(\d+)`)
+
+// Returns the number of the post quoted at a screen position in the comment
+// pane, if any
+func (l layout) quoteAt(x, y int) (int, bool) {
+	i := y - l.commentTop
+	if !l.inComment(x, y) || i >= len(l.comment) {
+		return 0, false
+	}
+	x -= l.commentLeft
+	line := strings.ReplaceAll(l.comment[i], "\t", "    ") // as rendered by lipgloss
+	for _, m := range quotelinkText.FindAllStringSubmatchIndex(line, -1) {
+		if x >= m[0] && x < m[1] {
+			id, _ := strconv.Atoi(line[m[2]:m[3]])
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+const wheelLines = 3
+
+func (m *ThreadViewer) handleMouse(msg tea.MouseMsg) tea.Cmd {
+	ev := tea.MouseEvent(msg)
+	var d int
+	switch {
+	case ev.Action != tea.MouseActionPress:
+		return nil
+	case ev.Button == tea.MouseButtonWheelUp:
+		d = -1
+	case ev.Button == tea.MouseButtonWheelDown:
+		d = 1
+	case ev.Button != tea.MouseButtonLeft:
+		return nil
+	}
+	m.status = ""
+
+	switch m.mode() {
+	case searchMode:
+		return nil
+
+	case panelMode, boardsMode:
+		n := len(m.panelItems())
+		switch i, ok := m.layout.row(ev.X, ev.Y); {
+		case d != 0:
+			m.panelCursor = max(0, min(m.panelCursor+d, n-1))
+		case ok && i == m.panelCursor: // second click opens
+			return m.updatePanel("open")
+		case ok:
+			m.panelCursor = i
+		}
+		return nil
+	}
+	if len(m.thread.Posts) == 0 {
+		return nil
+	}
+
+	switch {
+	case d != 0 && m.layout.inComment(ev.X, ev.Y):
+		return m.scrollComment(d * wheelLines)
+
+	case d != 0: // unlike j and k, does not wrap around
+		m.cursor = max(0, min(m.cursor+d, len(m.thread.Posts)-1))
+
+	case m.layout.inComment(ev.X, ev.Y):
+		id, ok := m.layout.quoteAt(ev.X, ev.Y)
+		if !ok {
+			return nil
+		}
+		idx, err := m.thread.getIndex(id)
+		if err != nil {
+			m.status = "post not found"
+			return nil
+		}
+		m.jumpTo(idx)
+
+	default:
+		i, ok := m.layout.row(ev.X, ev.Y)
+		if !ok {
+			return nil
+		}
+		if i == m.cursor && m.catalog { // second click opens
+			return m.settle(m.updateScreen(), m.open())
+		}
+		m.cursor = i
+	}
+	return m.settle(m.updateScreen())
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLayout(t *testing.T) {
+	l := layout{
+		listTop:    2,
+		rows:       []int{4, -1, 5},
+		commentTop: 10,
+		comment:    []string{">>123 (OP)", "\t>>456 yes", "see >>789"},
+	}
+
+	for y, want := range map[int]int{2: 4, 4: 5} {
+		i, ok := l.row(0, y)
+		assert.True(t, ok)
+		assert.Equal(t, want, i)
+	}
+	for _, y := range []int{1, 3, 5} { // border, divider, past the end
+		_, ok := l.row(0, y)
+		assert.False(t, ok, y)
+	}
+
+	assert.False(t, l.inComment(0, 9))
+	assert.True(t, l.inComment(0, 10))
+
+	for _, c := range []struct{ x, y, want int }{
+		{0, 10, 123},
+		{4, 10, 123},
+		{6, 10, 0},
+		{4, 11, 456}, // after tab
+		{2, 11, 0},
+		{6, 12, 789},
+		{0, 13, 0},
+	} {
+		id, ok := l.quoteAt(c.x, c.y)
+		assert.Equal(t, c.want != 0, ok, c)
+		assert.Equal(t, c.want, id, c)
+	}
+}
+
+func TestWheel(t *testing.T) {
+	m := testViewer(3)
+	m.catalog = true
+	m.layout.commentTop = -1
+	wheel := func(b tea.MouseButton) {
+		m.handleMouse(tea.MouseMsg{Button: b, Action: tea.MouseActionPress})
+	}
+
+	wheel(tea.MouseButtonWheelDown)
+	assert.Equal(t, 1, m.cursor)
+	m.cursor = 2
+	wheel(tea.MouseButtonWheelDown) // clamped, not wrapped
+	assert.Equal(t, 2, m.cursor)
+	m.cursor = 0
+	wheel(tea.MouseButtonWheelUp)
+	assert.Equal(t, 0, m.cursor)
+}
@@ -0,0 +1,217 @@
+// Opening boards, threads and posts by reference: URLs
+// (https://boards.4chan.org/g/thread/123#p456), cross-board links
+// (>>>/g/123) and shorthand (g/123)
+
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/term"
+)
+
+type ref struct {
+	board  string
+	thread int // thread, or (unless given as /thread/) any post
+	post   int // from the #p456 anchor; 0 if none
+}
+
+func (r ref) String() string {
+	s := "/" + r.board + "/"
+	if r.thread != 0 {
+		s += strconv.Itoa(r.thread)
+	}
+	if r.post != 0 {
+		s += "#p" + strconv.Itoa(r.post)
+	}
+	return s
+}
+
+var boardName = regexp.MustCompile(`^[a-z0-9]+$`)
+
+// Parse a reference to a board, thread or post
+func parseRef(s string) (r ref, err error) {
+	s, anchor, _ := strings.Cut(strings.TrimSpace(s), "#")
+	if anchor != "" {
+		if r.post, err = strconv.Atoi(strings.TrimLeft(anchor, "pq")); err != nil {
+			return r, fmt.Errorf("invalid post: #%s", anchor)
+		}
+	}
+
+	s = strings.TrimPrefix(s, ">>>")
+	if !strings.Contains(s, "://") && strings.HasPrefix(s, "boards.") {
+		s = "https://" + s
+	}
+	if u, err := url.Parse(s); err == nil && u.Host != "" {
+		switch u.Host {
+		case "boards.4chan.org", "boards.4channel.org":
+		default:
+			return r, fmt.Errorf("not a 4chan url: %s", s)
+		}
+		s = u.Path
+	}
+
+	// board[/thread]/id[/slug]
+	parts := strings.FieldsFunc(s, func(c rune) bool { return c == '/' })
+	if len(parts) == 0 || !boardName.MatchString(parts[0]) {
+		return r, fmt.Errorf("not a board or thread: %s", s)
+	}
+	r.board, parts = parts[0], parts[1:]
+	if len(parts) > 0 && parts[0] == "thread" {
+		parts = parts[1:]
+	}
+	if len(parts) > 0 {
+		if r.thread, err = strconv.Atoi(parts[0]); err != nil {
+			return r, fmt.Errorf("not a thread: %s", parts[0])
+		}
+	}
+	return r, nil
+}
+
+// Find a reference in text, e.g. from the clipboard. Words that look like
+// links are tried first.
+func findRef(text string) (ref, error) {
+	words := strings.Fields(text)
+	slices.SortStableFunc(words, func(a, b string) int {
+		return cmp.Compare(strings.Count(b, "/"), strings.Count(a, "/"))
+	})
+	for _, w := range words {
+		if r, err := parseRef(w); err == nil && (r.thread != 0 || len(words) == 1) {
+			return r, nil
+		}
+	}
+	return ref{}, fmt.Errorf("no thread found in %q", text)
+}
+
+// Max threads fetched when looking for the thread of a post
+const maxPostCandidates = 5
+
+// Fetch the referenced thread; if there is no thread with that number, look
+// for a thread in the catalog containing it as a reply. Returns the index of
+// the referenced post, or -1 if none.
+func (r ref) fetch() (*Thread, int, error) {
+	post := cmp.Or(r.post, r.thread)
+	t, err := fetchThread(r.board, r.thread)
+	if errors.Is(err, errNotFound) && r.post == 0 {
+		t, err = findPost(r.board, r.thread)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	idx, err := t.getIndex(post)
+	if err != nil || r.post == 0 && idx == 0 {
+		return t, -1, nil
+	}
+	return t, idx, nil
+}
+
+// Returns the thread containing a post, among the threads whose range of
+// post numbers (as of the catalog) includes it, nearest first
+func findPost(board string, num int) (*Thread, error) {
+	c, err := fetchCatalog(board)
+	if err != nil {
+		return nil, err
+	}
+	var candidates []*Post
+	for _, op := range c.Posts {
+		last := op.Num
+		if n := len(op.LastReplies); n > 0 {
+			last = op.LastReplies[n-1].Num
+		}
+		if op.Num < num && num <= last {
+			candidates = append(candidates, op)
+		}
+	}
+	slices.SortFunc(candidates, func(a, b *Post) int { return cmp.Compare(b.Num, a.Num) })
+
+	for _, op := range candidates[:min(len(candidates), maxPostCandidates)] {
+		t, err := fetchThread(board, op.Num)
+		if err != nil {
+			continue
+		}
+		if _, err := t.getIndex(num); err == nil {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("/%s/%d: %w", board, num, errNotFound)
+}
+
+// Returns a viewer of the referenced catalog or thread
+func (r ref) viewer() (*ThreadViewer, error) {
+	if r.thread == 0 {
+		c, err := fetchCatalog(r.board)
+		if err != nil {
+			return nil, err
+		}
+		return newViewer(&buffer{thread: Thread(c), catalog: true}), nil
+	}
+	t, idx, err := r.fetch()
+	if err != nil {
+		return nil, err
+	}
+	m := newViewer(&buffer{thread: *t})
+	if idx >= 0 {
+		m.start = t.Posts[idx].Num
+	}
+	return m, nil
+}
+
+// Read the clipboard (X11 or Wayland)
+func readClipboard() (string, error) {
+	b, err := exec.Command("xclip", "-sel", "c", "-o").Output()
+	if err != nil {
+		b, err = exec.Command("wl-paste", "-n").Output()
+	}
+	return string(b), err
+}
+
+// Usage: ibb open [url]
+func openCmd(args []string) {
+	var text string
+	var opts []tea.ProgramOption
+	var err error
+	switch {
+	case len(args) > 1:
+		fmt.Fprintln(os.Stderr, "usage: ibb open [url]  (default: read from stdin, or the clipboard)")
+		os.Exit(2)
+	case len(args) == 1:
+		text = args[0]
+	case !term.IsTerminal(os.Stdin.Fd()):
+		var b []byte
+		b, err = io.ReadAll(os.Stdin)
+		text = string(b)
+		opts = append(opts, tea.WithInputTTY()) // stdin is not the keyboard
+	default:
+		text, err = readClipboard()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	r, err := findRef(text)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	m, err := r.viewer()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, r, err)
+		os.Exit(1)
+	}
+	p := tea.NewProgram(m, append(viewerOptions(), opts...)...)
+	if _, err := p.Run(); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRef(t *testing.T) {
+	for s, want := range map[string]ref{
+		"g":                                     {board: "g"},
+		"/g/":                                   {board: "g"},
+		">>>/g/":                                {board: "g"},
+		"g/123":                                 {board: "g", thread: 123},
+		">>>/g/123":                             {board: "g", thread: 123},
+		"/g/thread/123":                         {board: "g", thread: 123},
+		"https://boards.4chan.org/g/":           {board: "g"},
+		"https://boards.4chan.org/g/thread/123": {board: "g", thread: 123},
+		"http://boards.4channel.org/g/thread/123/slug#p456": {board: "g", thread: 123, post: 456},
+		"boards.4chan.org/g/thread/123#q456":                {board: "g", thread: 123, post: 456},
+	} {
+		r, err := parseRef(s)
+		assert.NoError(t, err, s)
+		assert.Equal(t, want, r, s)
+	}
+
+	for _, s := range []string{
+		"",
+		"G",
+		"g/foo",
+		"g/123#pfoo",
+		"https://example.com/g/thread/123",
+	} {
+		_, err := parseRef(s)
+		assert.Error(t, err, s)
+	}
+
+	assert.Equal(t, "/g/123#p456", ref{board: "g", thread: 123, post: 456}.String())
+}
+
+func TestFindRef(t *testing.T) {
+	for text, want := range map[string]ref{
+		"g\n": {board: "g"},
+		"see https://boards.4chan.org/g/thread/123#p456 lol": {board: "g", thread: 123, post: 456},
+		"based >>>/v/789": {board: "v", thread: 789},
+	} {
+		r, err := findRef(text)
+		assert.NoError(t, err, text)
+		assert.Equal(t, want, r, text)
+	}
+
+	_, err := findRef("no links here")
+	assert.Error(t, err)
+}
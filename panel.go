@@ -0,0 +1,259 @@
+// Panels: lists shown in place of the posts, e.g. the watchlist
+
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/list"
+)
+
+type panel int
+
+const (
+	noPanel panel = iota
+	watchlistPanel
+	historyPanel
+	hiddenPanel
+	helpPanel
+	buffersPanel
+	boardsPanel
+)
+
+func (p panel) String() string {
+	switch p {
+	case watchlistPanel:
+		return "watchlist"
+	case historyPanel:
+		return "history"
+	case hiddenPanel:
+		return "hidden"
+	case helpPanel:
+		return "help"
+	case buffersPanel:
+		return "buffers"
+	case boardsPanel:
+		return "boards"
+	default:
+		return ""
+	}
+}
+
+// Returns the items of the current panel
+func (m *ThreadViewer) panelItems() []string {
+	switch m.panel {
+	case watchlistPanel:
+		var items []string
+		for _, e := range watched.list() {
+			items = append(items, e.String())
+		}
+		return items
+	case historyPanel:
+		var items []string
+		for _, e := range state.history() {
+			items = append(items, e.String())
+		}
+		return items
+	case hiddenPanel:
+		var items []string
+		for _, e := range state.hidden() {
+			items = append(items, e.String())
+		}
+		return items
+	case helpPanel:
+		items, _ := m.helpItems()
+		return items
+	case buffersPanel:
+		var items []string
+		for i, b := range m.buffers {
+			current := " "
+			if b == m.buffer {
+				current = "%"
+			}
+			items = append(items, fmt.Sprintf("%d %s %s", i+1, current, b))
+		}
+		return items
+	case boardsPanel:
+		var items []string
+		for _, board := range m.boards {
+			selected := " "
+			if m.selected[board] {
+				selected = "*"
+			}
+			items = append(items, fmt.Sprintf("%s /%s/", selected, board))
+		}
+		return items
+	default:
+		return nil
+	}
+}
+
+func (m *ThreadViewer) closePanel() tea.Cmd {
+	m.panel = noPanel
+	return m.updateScreen()
+}
+
+// Open a panel, or close it if already open
+func (m *ThreadViewer) togglePanel(p panel) tea.Cmd {
+	if m.panel == p {
+		return m.closePanel()
+	}
+	m.panel = p
+	m.panelCursor = 0
+	return tea.ClearScreen
+}
+
+// Handle an action while a panel is open
+func (m *ThreadViewer) updatePanel(action string) tea.Cmd {
+	n := len(m.panelItems())
+
+	switch action {
+	case "close":
+		return m.closePanel()
+	case "watchlist":
+		return m.togglePanel(watchlistPanel)
+	case "history":
+		return m.togglePanel(historyPanel)
+	case "hidden":
+		return m.togglePanel(hiddenPanel)
+	case "help":
+		return m.showHelp()
+	case "buffers":
+		return m.togglePanel(buffersPanel)
+	case "boards":
+		return m.showBoards()
+
+	case "down":
+		m.panelCursor = min(m.panelCursor+1, max(0, n-1))
+	case "up":
+		m.panelCursor = max(m.panelCursor-1, 0)
+	case "top":
+		m.panelCursor = 0
+	case "bottom":
+		m.panelCursor = max(0, n-1)
+	}
+
+	switch m.panel {
+	case watchlistPanel:
+		return m.updateWatchlistPanel(action)
+	case historyPanel:
+		return m.updateHistoryPanel(action)
+	case hiddenPanel:
+		return m.updateHiddenPanel(action)
+	case buffersPanel:
+		return m.updateBuffersPanel(action)
+	case boardsPanel:
+		return m.updateBoardsPanel(action)
+	}
+	return nil
+}
+
+func (m *ThreadViewer) updateWatchlistPanel(action string) tea.Cmd {
+	entries := watched.list()
+	if len(entries) == 0 {
+		return nil
+	}
+	e := entries[m.panelCursor]
+
+	switch action {
+	case "remove": // unwatch
+		watched.remove(e.Board, e.Thread)
+		m.panelCursor = max(0, min(m.panelCursor, len(entries)-2))
+
+	case "open": // open at first unread post
+		t, err := fetchThread(e.Board, e.Thread)
+		if err != nil {
+			m.status = err.Error()
+			return nil
+		}
+		lastRead := state.readState(e.Board, e.Thread).Read
+		post := t.newest()
+		if i := slices.IndexFunc(t.Posts, func(p *Post) bool { return p.Num > lastRead }); i >= 0 {
+			post = t.Posts[i].Num
+		}
+		m.openTab(t, post)
+		return m.updateScreen()
+	}
+	return nil
+}
+
+func (m *ThreadViewer) updateHistoryPanel(action string) tea.Cmd {
+	entries := state.history()
+	if len(entries) == 0 || action != "open" {
+		return nil
+	}
+	e := entries[m.panelCursor]
+	t, err := fetchThread(e.Board, e.Thread)
+	if err != nil {
+		m.status = err.Error()
+		return nil
+	}
+	m.openTab(t, 0)
+	return m.updateScreen()
+}
+
+func (m *ThreadViewer) viewPanel() string {
+	items := m.panelItems()
+	var dim []bool
+	if m.panel == helpPanel {
+		_, dim = m.helpItems()
+	}
+
+	l := list.New().Enumerator(blankEnum)
+	if len(items) == 0 {
+		l.Item("  (empty)")
+	}
+
+	start, end := getScrollWindow(m.panelCursor, &items, (m.height-4)/2)
+	m.layout = layout{listTop: 2, commentTop: -1}
+	for i := start; i < end; i++ {
+		m.layout.rows = append(m.layout.rows, i)
+	}
+	for i, item := range items[start:end] {
+		item = fmt.Sprintf("%s %s", isSelected[start+i == m.panelCursor], item)
+		if len(item) > m.width-5 {
+			item = item[:m.width-5]
+		}
+		if dim != nil && dim[start+i] {
+			item = config.styles.hidden.Render(item)
+		}
+		l.Item(item)
+	}
+
+	header := fmt.Sprintf(" %s [%d] ", m.panel, len(items))
+	if m.panel == helpPanel {
+		header += fmt.Sprintf("[in %s; dimmed actions are unavailable] ", m.helpMode)
+	}
+	if m.typingQuery {
+		header += fmt.Sprintf("[search %s: %s] ", m.searchedBoards(), m.boardQuery)
+	}
+	if m.status != "" {
+		header += fmt.Sprintf("[%s] ", m.status)
+	}
+
+	return lipgloss.JoinVertical(
+		lipgloss.Left,
+		header,
+		lipgloss.NewStyle().
+			Width(m.width-3).
+			MaxHeight(m.height-1).
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(config.styles.border).
+			Render(l.String()),
+	)
+}
+
+func (m *ThreadViewer) updateHiddenPanel(action string) tea.Cmd {
+	entries := state.hidden()
+	if len(entries) == 0 || action != "remove" {
+		return nil
+	}
+	e := entries[m.panelCursor]
+	state.unhide(e)
+	m.panelCursor = max(0, min(m.panelCursor, len(entries)-2))
+	m.status = "unhidden " + e.String()
+	return m.reload()
+}
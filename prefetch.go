@@ -0,0 +1,82 @@
+// Background image downloads
+
+package main
+
+import (
+	"log"
+	"sync/atomic"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Posts before/after the cursor whose images are downloaded in the
+// background
+const prefetchDist = 3
+
+type prefetchJob struct {
+	post *Post
+	gen  int64
+}
+
+// Downloads images with a fixed number of workers. Downloads of the same file
+// are shared, so an image that is being prefetched is not fetched again when
+// the cursor lands on it.
+type prefetcher struct {
+	jobs  chan prefetchJob
+	group singleflight.Group
+
+	// incremented on every call to prefetch; jobs from older generations
+	// are stale (the cursor has since moved away) and are dropped
+	gen atomic.Int64
+}
+
+var downloads = newPrefetcher(4)
+
+func newPrefetcher(workers int) *prefetcher {
+	pf := &prefetcher{jobs: make(chan prefetchJob, 4*workers)}
+	for range workers {
+		go pf.work()
+	}
+	return pf
+}
+
+func (pf *prefetcher) work() {
+	for j := range pf.jobs {
+		if j.gen != pf.gen.Load() {
+			continue
+		}
+		if err := pf.fetch(j.post); err != nil {
+			log.Println("prefetch failed:", err)
+		}
+	}
+}
+
+// Download the displayed image of a post, blocking until it is in the cache.
+// If the image is already being downloaded, wait for that download instead.
+func (pf *prefetcher) fetch(p *Post) error {
+	f, err := p.displayFile()
+	if err != nil {
+		return err
+	}
+	_, err, _ = pf.group.Do(f.url, func() (any, error) {
+		return nil, download(f)
+	})
+	return err
+}
+
+// Queue background downloads for the given posts, superseding any queued
+// downloads that have not started yet. Never blocks; if the queue is full,
+// the remaining posts are skipped.
+func (pf *prefetcher) prefetch(posts []*Post) {
+	gen := pf.gen.Add(1)
+	for _, p := range posts {
+		if _, err := p.displayFile(); err != nil {
+			continue
+		}
+		select {
+		case pf.jobs <- prefetchJob{post: p, gen: gen}:
+		default:
+			return
+		}
+	}
+}
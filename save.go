@@ -0,0 +1,240 @@
+// Saving images to user-defined destinations
+
+package main
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Values substituted into the save destination template
+type saveVars struct {
+	board    string
+	thread   int
+	subject  string
+	post     int
+	filename string // original filename, without extension
+	tim      int
+	md5      string // base64, as in the API
+	ext      string
+}
+
+// op is the first post of the thread p is in
+func (p Post) saveVars(op *Post) saveVars {
+	return saveVars{
+		board:    p.Board,
+		thread:   op.Num,
+		subject:  op.Subject,
+		post:     p.Num,
+		filename: p.Filename,
+		tim:      p.Time,
+		md5:      p.MD5,
+		ext:      p.Ext,
+	}
+}
+
+var placeholder = regexp.MustCompile(`\{[a-z0-9]*\}`)
+
+// Expand a destination template. A leading ~ is replaced with $HOME. Available
+// placeholders:
+//
+//	{board}     g
+//	{thread}    thread number
+//	{subject}   lowercased thread subject (thread number if empty)
+//	{post}      post number
+//	{filename}  original filename, without extension
+//	{tim}       upload timestamp (4chan's filename)
+//	{md5}       md5 of the file, in hex
+//	{ext}       extension, including the leading dot
+//
+// Values are sanitized to be safe as path components, and may never introduce
+// additional directories.
+func expandTemplate(tmpl string, v saveVars) (string, error) {
+	if tmpl == "" {
+		return "", fmt.Errorf("empty template")
+	}
+
+	var md5hex string
+	if b, err := base64.StdEncoding.DecodeString(v.md5); err == nil {
+		md5hex = hex.EncodeToString(b)
+	}
+
+	subject := strings.ToLower(v.subject)
+	if sanitize(subject) == "_" {
+		subject = strconv.Itoa(v.thread)
+	}
+
+	values := map[string]string{
+		"{board}":    v.board,
+		"{thread}":   strconv.Itoa(v.thread),
+		"{subject}":  subject,
+		"{post}":     strconv.Itoa(v.post),
+		"{filename}": v.filename,
+		"{tim}":      strconv.Itoa(v.tim),
+		"{md5}":      md5hex,
+		"{ext}":      v.ext,
+	}
+
+	var err error
+	path := placeholder.ReplaceAllStringFunc(tmpl, func(s string) string {
+		val, ok := values[s]
+		if !ok {
+			err = fmt.Errorf("unknown placeholder %s", s)
+			return s
+		}
+		if s == "{ext}" { // may be empty, and must keep its dot
+			return strings.ReplaceAll(val, "/", "")
+		}
+		return sanitize(val)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(home, path[1:])
+	}
+	return filepath.Clean(path), nil
+}
+
+// Make a string safe to use as a single path component
+func sanitize(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r == '/', r == '\\', unicode.IsControl(r):
+			return '_'
+		}
+		return r
+	}, s)
+	// no hidden files, "." or ".."
+	s = strings.Trim(s, ". ")
+	if s == "" {
+		return "_"
+	}
+	return s
+}
+
+// Find a free path by appending a counter, e.g. foo_1.jpg. If one of the
+// paths taken already has the given (base64) md5, e.g. from a previous run,
+// that path is returned instead, and saved is true.
+func freePath(path string, sum string) (free string, saved bool) {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for i := 1; ; i++ {
+		p := fmt.Sprintf("%s_%d%s", base, i, ext)
+		if _, err := os.Stat(p); err != nil {
+			return p, false
+		}
+		if sameFile(p, sum) {
+			return p, true
+		}
+	}
+}
+
+// Save the original image of a post to the destination configured in
+// config.Save. Returns the destination, and whether anything was written
+// (false if skipped due to collision). op is the first post of the thread p
+// is in.
+func (p Post) saveImage(op *Post) (dest string, saved bool, err error) {
+	f, err := p.original()
+	if err != nil {
+		return "", false, err
+	}
+	// only the thumbnail may have been downloaded
+	if err := download(f); err != nil {
+		return "", false, err
+	}
+
+	dest, err = expandTemplate(config.Save.Dest, p.saveVars(op))
+	if err != nil {
+		return "", false, err
+	}
+
+	if _, err := os.Stat(dest); err == nil {
+		// regardless of policy, there is no point saving the same file
+		// twice
+		if sameFile(dest, p.MD5) {
+			return dest, false, nil
+		}
+		switch config.Save.Collision {
+		case "skip":
+			return dest, false, nil
+		case "rename":
+			var saved bool
+			if dest, saved = freePath(dest, p.MD5); saved {
+				return dest, false, nil
+			}
+		case "overwrite":
+			if err := os.Remove(dest); err != nil {
+				return dest, false, err
+			}
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+		return dest, false, err
+	}
+
+	log.Println(f.path(), "->", dest)
+
+	// cache and destination are usually on the same filesystem
+	if err := os.Link(f.path(), dest); err == nil {
+		return dest, true, nil
+	}
+	return dest, true, copyFile(f.path(), dest)
+}
+
+// Check if the file at path has the given (base64) md5
+func sameFile(path string, sum string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	hash := md5.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return false
+	}
+	return base64.StdEncoding.EncodeToString(hash.Sum(nil)) == sum
+}
+
+// Copy via a temp file, so that an interrupted copy does not leave a
+// truncated file at dest
+func copyFile(src string, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.CreateTemp(filepath.Dir(dest), "."+filepath.Base(dest)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name()) // no-op after successful rename
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	_ = os.Chmod(out.Name(), 0664)
+	return os.Rename(out.Name(), dest)
+}
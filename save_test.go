@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	v := saveVars{
+		board:    "g",
+		thread:   123,
+		subject:  "Desktop Thread",
+		post:     456,
+		filename: "../../etc/passwd",
+		tim:      1700000000000,
+		md5:      "AAECAwQFBgcICQoLDA0ODw==",
+		ext:      ".png",
+	}
+
+	home, _ := os.UserHomeDir()
+
+	for tmpl, want := range map[string]string{
+		"~/{subject}/{tim}{ext}":                filepath.Join(home, "desktop thread/1700000000000.png"),
+		"/x/{board}/{thread}/{post}_{filename}": "/x/g/123/456__.._etc_passwd",
+		"/x/{md5}{ext}":                         "/x/000102030405060708090a0b0c0d0e0f.png",
+	} {
+		got, err := expandTemplate(tmpl, v)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	// empty subject falls back to thread number
+	v.subject = ""
+	got, _ := expandTemplate("/x/{subject}/{tim}{ext}", v)
+	assert.Equal(t, "/x/123/1700000000000.png", got)
+
+	_, err := expandTemplate("/x/{foo}", v)
+	assert.Error(t, err)
+
+	assert.Equal(t, "_", sanitize(".."))
+	assert.Equal(t, "a_b", sanitize("a/b"))
+}
+
+func TestSaveVars(t *testing.T) {
+	op := &Post{Board: "g", Num: 1, Subject: "first"}
+	reply := &Post{Board: "g", Num: 3, Time: 1700000000000, Ext: ".png"}
+	other := &Post{Board: "g", Num: 2, Subject: "second", Time: 1700000000001, Ext: ".jpg"}
+
+	// in a thread, the thread is that of the first post
+	b := &buffer{thread: Thread{Board: "g", Posts: []*Post{op, reply}}, cursor: 1}
+	v := b.currentPost().saveVars(b.currentOP())
+	assert.Equal(t, 1, v.thread)
+	assert.Equal(t, "first", v.subject)
+	assert.Equal(t, 3, v.post)
+
+	// in the catalog, each post is the first post of its own thread
+	b = &buffer{thread: Thread{Board: "g", Posts: []*Post{op, other}}, catalog: true, cursor: 1}
+	v = b.currentPost().saveVars(b.currentOP())
+	assert.Equal(t, 2, v.thread)
+	assert.Equal(t, "second", v.subject)
+	assert.Equal(t, 2, v.post)
+}
+
+func TestFreePath(t *testing.T) {
+	dir := t.TempDir()
+	sum := func(b string) string {
+		s := md5.Sum([]byte(b))
+		return base64.StdEncoding.EncodeToString(s[:])
+	}
+	for name, body := range map[string]string{"a.jpg": "a", "a_1.jpg": "b"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644))
+	}
+
+	// saved by a previous run
+	p, saved := freePath(filepath.Join(dir, "a.jpg"), sum("b"))
+	assert.Equal(t, filepath.Join(dir, "a_1.jpg"), p)
+	assert.True(t, saved)
+
+	p, saved = freePath(filepath.Join(dir, "a.jpg"), sum("c"))
+	assert.Equal(t, filepath.Join(dir, "a_2.jpg"), p)
+	assert.False(t, saved)
+}
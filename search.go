@@ -0,0 +1,79 @@
+// Post search: case-insensitive regex, optionally scoped to a single field
+
+package main
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// Fields that a query may be scoped to, e.g. "name:anonymous"
+var searchFields = []string{"sub", "name", "trip", "file", "id"}
+
+type query struct {
+	field string // empty for subject and comment
+	re    *regexp.Regexp
+}
+
+// Parse a query of the form [field:]pattern. Patterns that are not valid
+// regexes (often just incomplete ones, while typing) are matched literally.
+func parseQuery(s string) query {
+	var q query
+	if field, pattern, ok := strings.Cut(s, ":"); ok {
+		for _, f := range searchFields {
+			if field == f {
+				q.field = f
+				s = pattern
+			}
+		}
+	}
+
+	re, err := regexp.Compile("(?i)" + s)
+	if err != nil {
+		re = regexp.MustCompile("(?i)" + regexp.QuoteMeta(s))
+	}
+	q.re = re
+	return q
+}
+
+// Comment as plain text, without markup
+func (p Post) text() string {
+	c := strings.ReplaceAll(p.Comment, "\n", " ")
+	return html.UnescapeString(stripHtmlTags(c))
+}
+
+// Returns the text of a post that a query searches
+func (q query) target(p *Post) string {
+	switch q.field {
+	case "sub":
+		return html.UnescapeString(p.Subject)
+	case "name":
+		return p.Name
+	case "trip":
+		return p.Trip
+	case "file":
+		if p.Ext == "" {
+			return ""
+		}
+		return p.Filename + p.Ext
+	case "id":
+		return p.ID
+	default:
+		return strings.TrimSpace(html.UnescapeString(p.Subject) + " " + p.text())
+	}
+}
+
+func (q query) match(p *Post) bool {
+	return q.re.MatchString(q.target(p))
+}
+
+// Returns indices of all posts matching the query
+func (t *Thread) filterPosts(q query) (matches []int) {
+	for idx, p := range t.Posts {
+		if q.match(p) {
+			matches = append(matches, idx)
+		}
+	}
+	return matches
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearch(t *testing.T) {
+	thread := Thread{Board: "g", Posts: []*Post{
+		{Num: 1, Subject: "Desktop thread", Comment: "post your<br>desktops", Name: "Anonymous"},
+		{Num: 2, Comment: "&gt;&gt;1<br>nice", Name: "Anonymous", Trip: "!abc", ID: "Xy12"},
+		{Num: 3, Comment: "Brown &amp; co", Name: "moot", Filename: "rice", Ext: ".png"},
+	}}
+
+	for q, want := range map[string][]int{
+		"br":         {2},       // not the <br> tags
+		"desktop":    {0},       // subject and comment
+		">>1":        {1},       // unescaped
+		"^brown & c": {2},       // regex, case-insensitive
+		"(":          nil,       // invalid regex is matched literally
+		"name:^anon": {0, 1},    // field-scoped
+		"trip:abc":   {1},       // field-scoped
+		"file:png$":  {2},       // filename and extension
+		"id:xy12":    {1},       // poster id
+		"sub:thread": {0},       // subject only
+		"foo:bar":    nil,       // unknown prefix is part of the pattern
+		".":          {0, 1, 2}, // all
+	} {
+		assert.Equal(t, want, thread.filterPosts(parseQuery(q)), q)
+	}
+}
+
+func TestNextMatch(t *testing.T) {
+	m := newViewer(&buffer{matches: []int{2, 5, 7}, input: "x", cursor: 3})
+
+	m.nextMatch(1)
+	assert.Equal(t, 5, m.cursor)
+	assert.Equal(t, 2, m.matchIndex())
+	m.nextMatch(1)
+	m.nextMatch(1) // wraps
+	assert.Equal(t, 2, m.cursor)
+	m.nextMatch(-1) // wraps
+	assert.Equal(t, 7, m.cursor)
+	m.nextMatch(0)
+	assert.Equal(t, 7, m.cursor)
+
+	m.cursor = 6
+	assert.Equal(t, 0, m.matchIndex())
+	m.nextMatch(-1)
+	assert.Equal(t, 5, m.cursor)
+}
+
+func TestBoardList(t *testing.T) {
+	m := newViewer(&buffer{thread: Thread{Posts: []*Post{{Num: 1}}}, catalog: true})
+	m.boards = []string{"a", "g", "v"}
+	m.panel = boardsPanel
+	assert.Equal(t, boardsMode, m.mode())
+	assert.Equal(t, "all boards", m.searchedBoards())
+
+	m.panelCursor = 1
+	m.updatePanel("select-board")
+	m.updatePanel("select-board")
+	assert.Equal(t, []string{"g", "v"}, m.selectedBoards())
+	assert.Equal(t, []string{"  /a/", "* /g/", "* /v/"}, m.panelItems())
+
+	m.updatePanel("search-boards")
+	assert.Equal(t, searchMode, m.mode())
+	for _, r := range "gpu" {
+		m.updateBoardQuery(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}}, nil, false)
+	}
+	m.updateBoardQuery(tea.KeyMsg{Type: tea.KeyBackspace}, nil, false)
+	assert.Equal(t, "gp", m.boardQuery)
+
+	cmd := m.updateBoardQuery(tea.KeyMsg{Type: tea.KeyEnter}, findAction("search-accept"), true)
+	assert.NotNil(t, cmd)
+	assert.Equal(t, boardsMode, m.mode())
+	assert.Equal(t, `search "gp" in /g/, /v/...`, m.status)
+}
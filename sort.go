@@ -0,0 +1,89 @@
+// Catalog sort modes
+
+package main
+
+import (
+	"cmp"
+	"slices"
+)
+
+type sortMode int
+
+const (
+	sortBump sortMode = iota // API order
+	sortCreated
+	sortLastReply
+	sortReplies
+	sortImages
+	sortUniqueIPs
+)
+
+var sortModes = []sortMode{sortBump, sortCreated, sortLastReply, sortReplies, sortImages, sortUniqueIPs}
+
+func (s sortMode) String() string {
+	switch s {
+	case sortCreated:
+		return "created"
+	case sortLastReply:
+		return "last reply"
+	case sortReplies:
+		return "replies"
+	case sortImages:
+		return "images"
+	case sortUniqueIPs:
+		return "unique ips"
+	default:
+		return "bump"
+	}
+}
+
+func (s sortMode) next() sortMode {
+	return sortModes[(slices.Index(sortModes, s)+1)%len(sortModes)]
+}
+
+// Sort key of a thread; larger is sorted first
+func (s sortMode) key(p *Post) int {
+	switch s {
+	case sortCreated:
+		return p.Created
+	case sortLastReply:
+		return p.lastReplyTime()
+	case sortReplies:
+		return p.Replies
+	case sortImages:
+		return p.Images
+	case sortUniqueIPs:
+		return p.UniqueIPs
+	default:
+		return -p.bumpOrder
+	}
+}
+
+// Sort catalog threads in place. Pinned threads stay on top; ties keep bump
+// order.
+func (s sortMode) sort(posts []*Post) {
+	slices.SortStableFunc(posts, func(a, b *Post) int {
+		return cmp.Or(
+			pinnedFirst(a, b),
+			cmp.Compare(s.key(b), s.key(a)),
+			cmp.Compare(a.bumpOrder, b.bumpOrder),
+		)
+	})
+}
+
+// Returns the sort mode last used for a board. Modes are stored by name, so
+// that they may be reordered.
+func (s *stateStore) sortMode(board string) sortMode {
+	var name string
+	s.get(sortBucket, board, &name)
+	for _, mode := range sortModes {
+		if mode.String() == name {
+			return mode
+		}
+	}
+	return sortBump
+}
+
+func (s *stateStore) setSortMode(board string, mode sortMode) {
+	s.put(sortBucket, board, mode.String())
+}
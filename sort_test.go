@@ -0,0 +1,42 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortModes(t *testing.T) {
+	posts := []*Post{
+		{Num: 1, bumpOrder: 0, Created: 100, Replies: 5, Images: 1},
+		{Num: 2, bumpOrder: 1, Created: 300, Replies: 5, Images: 3,
+			LastReplies: []*Post{{Created: 310}}},
+		{Num: 3, bumpOrder: 2, Created: 200, Replies: 9, Images: 2,
+			LastReplies: []*Post{{Created: 250}, {Created: 400}}},
+	}
+	nums := func() (n []int) {
+		for _, p := range posts {
+			n = append(n, p.Num)
+		}
+		return n
+	}
+
+	for mode, want := range map[sortMode][]int{
+		sortCreated:   {2, 3, 1},
+		sortLastReply: {3, 2, 1},
+		sortReplies:   {3, 1, 2}, // tie keeps bump order
+		sortImages:    {2, 3, 1},
+		sortBump:      {1, 2, 3},
+	} {
+		mode.sort(posts)
+		assert.Equal(t, want, nums(), mode.String())
+	}
+
+	assert.Equal(t, sortBump, sortUniqueIPs.next())
+
+	s := &stateStore{path: filepath.Join(t.TempDir(), "state.db")}
+	assert.Equal(t, sortBump, s.sortMode("g"))
+	s.setSortMode("g", sortReplies)
+	assert.Equal(t, sortReplies, s.sortMode("g"))
+}
@@ -0,0 +1,189 @@
+// Split-pane layout: the posts list, and the image or comment pane either
+// below it (stacked) or to its right (side by side). The pane shows the
+// image, the comment, or both.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+var arrangements = []string{"auto", "stacked", "side-by-side"}
+
+// What the pane shows
+type paneMode int
+
+const (
+	paneImage   paneMode = iota // image, or comment if there is none
+	paneComment                 // comment, or image if there is none
+	paneBoth                    // comment flowing around the image
+)
+
+var paneModes = []string{"image", "comment", "both"}
+
+func (p paneMode) String() string {
+	return paneModes[p]
+}
+
+// What the pane of a new buffer shows
+func defaultPane() paneMode {
+	return paneMode(slices.Index(paneModes, config.Pane))
+}
+
+// Show the comment rather than the image, or vice versa
+func (m *ThreadViewer) toggleComment() tea.Cmd {
+	switch m.show {
+	case paneImage:
+		m.show = paneComment
+	default:
+		m.show = paneImage
+	}
+	return nil
+}
+
+// Show both image and comment, or just the image again
+func (m *ThreadViewer) toggleBoth() tea.Cmd {
+	switch m.show {
+	case paneBoth:
+		m.show = paneImage
+	default:
+		m.show = paneBoth
+	}
+	return nil
+}
+
+// Whether the pane shows the image and comment of a post, falling back to
+// whichever the post has
+func (m *ThreadViewer) shows(p *Post) (image, text bool) {
+	hasImage, hasText := p.Time != 0, p.Comment != ""
+	switch m.show {
+	case paneBoth:
+		return hasImage, hasText
+	case paneComment:
+		return !hasText && hasImage, hasText
+	default:
+		return hasImage, !hasImage && hasText
+	}
+}
+
+// Area of the image of a post, when the pane shows both: in the top left
+// corner, fitted into the left half of the pane if it is wider than tall (in
+// pixels), otherwise the top half. The comment flows around it; see
+// flowComment.
+func floatImage(pane *Size, p *Post) *Size {
+	area := &Size{x: pane.x, y: pane.y, width: pane.width, height: max(1, pane.height/2)}
+	if pane.width*CharWidthPx > pane.height*CharHeightPx {
+		area = &Size{x: pane.x, y: pane.y, width: max(1, pane.width/2), height: pane.height}
+	}
+	return fitImage(area, p.W, p.H)
+}
+
+// Shrink an area to an image of w x h pixels fitted into it, keeping its top
+// left corner. If the size of the image is unknown, the whole area is used.
+func fitImage(area *Size, w, h int) *Size {
+	if w <= 0 || h <= 0 {
+		return area
+	}
+	scale := min(
+		float64(area.width*CharWidthPx)/float64(w),
+		float64(area.height*CharHeightPx)/float64(h),
+	)
+	return &Size{
+		x:      area.x,
+		y:      area.y,
+		width:  max(1, min(area.width, int(math.Ceil(float64(w)*scale/CharWidthPx)))),
+		height: max(1, min(area.height, int(math.Ceil(float64(h)*scale/CharHeightPx)))),
+	}
+}
+
+// Areas of the image and the comment of a post (nil if not shown). When both
+// are shown, the comment takes the whole pane, flowing around the image. The
+// image is hidden while the comment is scrolled, which would otherwise run
+// under it.
+func (m *ThreadViewer) paneAreas(p *Post) (image, text *Size) {
+	showImage, showText := m.shows(p)
+	switch {
+	case m.short:
+		return nil, nil
+	case showImage && showText && m.commentFor == p.Num && m.comment.YOffset > 0:
+		return nil, m.pane()
+	case showImage && showText:
+		pane := m.pane()
+		return floatImage(pane, p), pane
+	case showImage:
+		return m.pane(), nil
+	case showText:
+		return nil, m.pane()
+	default:
+		return nil, nil
+	}
+}
+
+const (
+	minStackedHeight = 50 // below this, stacked layouts show no images
+	minSideHeight    = 12 // likewise, for side by side
+	minSplit         = 20 // percent of the screen taken by the list
+	maxSplit         = 80
+	splitStep        = 5
+)
+
+// Choose the orientation, and whether there is room for images, after a
+// resize or a change of layout. auto is side by side if the screen (in
+// pixels) is at least 16:9.
+func (m *ThreadViewer) resize(width, height int) {
+	m.width, m.height = width, height
+	switch m.arrange {
+	case "stacked":
+		m.side = false
+	case "side-by-side":
+		m.side = true
+	default:
+		m.side = 9*width*CharWidthPx >= 16*height*CharHeightPx
+	}
+	switch m.side {
+	case true:
+		m.short = height < minSideHeight
+	case false:
+		m.short = height < minStackedHeight
+	}
+}
+
+// Width of the posts list, including its border
+func (m *ThreadViewer) listWidth() int {
+	if m.side && !m.short {
+		return m.width * m.split / 100
+	}
+	return m.width
+}
+
+// Screen area of the image or comment pane
+func (m *ThreadViewer) pane() *Size {
+	switch {
+	case m.side:
+		x := m.listWidth()
+		return &Size{x: x, y: 1, width: max(1, m.width-x-1), height: max(1, m.height-1)}
+	default:
+		y := 1 + m.listRows() + 2 // below header and list (with border)
+		return &Size{y: y, width: m.width, height: max(1, m.height-y)}
+	}
+}
+
+// Move the split by d percent
+func (m *ThreadViewer) moveSplit(d int) tea.Cmd {
+	m.split = max(minSplit, min(m.split+d*m.count(), maxSplit))
+	m.status = fmt.Sprintf("split %d%%", m.split)
+	return nil
+}
+
+// Cycle between auto, stacked and side by side layouts
+func (m *ThreadViewer) cycleArrangement() tea.Cmd {
+	i := slices.Index(arrangements, m.arrange)
+	m.arrange = arrangements[(i+1)%len(arrangements)]
+	m.resize(m.width, m.height)
+	m.status = "layout: " + m.arrange
+	return tea.ClearScreen
+}
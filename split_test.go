@@ -0,0 +1,118 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplit(t *testing.T) {
+	m := newViewer(&buffer{})
+	assert.Equal(t, "auto", m.arrange)
+	assert.Equal(t, 50, m.split)
+
+	m.resize(120, 60) // 1080x900 px: stacked
+	assert.False(t, m.side)
+	assert.False(t, m.short)
+	assert.Equal(t, 30, m.listRows())
+	assert.Equal(t, &Size{y: 33, width: 120, height: 27}, m.pane())
+
+	m.resize(100, 40) // too short for images below the list
+	assert.True(t, m.short)
+
+	m.resize(240, 40) // 2160x600 px: side by side, with images
+	assert.True(t, m.side)
+	assert.False(t, m.short)
+	assert.Equal(t, 120, m.listWidth())
+	assert.Equal(t, &Size{x: 120, y: 1, width: 119, height: 39}, m.pane())
+
+	m.moveSplit(-splitStep)
+	assert.Equal(t, 108, m.listWidth())
+	m.moveCount = 10
+	m.moveSplit(splitStep)
+	assert.Equal(t, maxSplit, m.split)
+	m.moveCount = 0
+
+	m.cycleArrangement()
+	assert.Equal(t, "stacked", m.arrange)
+	assert.False(t, m.side)
+	assert.True(t, m.short)
+	m.cycleArrangement()
+	m.resize(80, 60)
+	assert.True(t, m.side)
+	m.cycleArrangement()
+	assert.Equal(t, "auto", m.arrange)
+	assert.False(t, m.side)
+
+	l := layout{listTop: 2, rows: []int{0, 1}, commentTop: 1, paneLeft: 40, commentLeft: 40}
+	_, ok := l.row(50, 2) // in the pane
+	assert.False(t, ok)
+	_, ok = l.row(10, 2)
+	assert.True(t, ok)
+	assert.False(t, l.inComment(10, 2))
+	assert.True(t, l.inComment(50, 2))
+}
+
+func TestPaneModes(t *testing.T) {
+	m := newViewer(&buffer{})
+	m.resize(120, 60)
+	image := &Post{Time: 1}
+	text := &Post{Comment: "a"}
+	both := &Post{Time: 1, Comment: "a"}
+
+	for _, c := range []struct {
+		show                   paneMode
+		post                   *Post
+		wantImage, wantComment bool
+	}{
+		{paneImage, both, true, false},
+		{paneImage, text, false, true},
+		{paneComment, both, false, true},
+		{paneComment, image, true, false},
+		{paneBoth, both, true, true},
+		{paneBoth, text, false, true},
+	} {
+		m.show = c.show
+		img, txt := m.shows(c.post)
+		assert.Equal(t, []bool{c.wantImage, c.wantComment}, []bool{img, txt}, c)
+	}
+
+	m.show = paneImage
+	m.toggleBoth()
+	assert.Equal(t, paneBoth, m.show)
+	m.toggleComment()
+	assert.Equal(t, paneImage, m.show)
+
+	// per buffer
+	first := m.buffer
+	m.toggleBoth()
+	m.insertBuffer(&buffer{})
+	assert.Equal(t, paneImage, m.show)
+	m.switchTo(first)
+	assert.Equal(t, paneBoth, m.show)
+
+	// wide pane: fitted into the left half
+	wide := &Size{x: 10, y: 1, width: 100, height: 20}
+	assert.Equal(t, &Size{x: 10, y: 1, width: 50, height: 20}, floatImage(wide, both))
+	square := &Post{W: 300, H: 300}
+	assert.Equal(t, &Size{x: 10, y: 1, width: 34, height: 20}, floatImage(wide, square))
+	// tall pane: fitted into the top half
+	tall := &Size{y: 1, width: 20, height: 40}
+	assert.Equal(t, &Size{y: 1, width: 20, height: 12}, floatImage(tall, square))
+
+	// the comment takes the whole pane; the image is hidden once scrolled
+	m.show = paneBoth
+	both.Num = 1
+	img, txt := m.paneAreas(both)
+	assert.Equal(t, m.pane(), txt)
+	assert.NotNil(t, img)
+	m.commentFor = 1
+	m.comment.YOffset = 1
+	img, _ = m.paneAreas(both)
+	assert.Nil(t, img)
+
+	m.resize(100, 40) // no room
+	img, txt = m.paneAreas(both)
+	assert.Nil(t, img)
+	assert.Nil(t, txt)
+}
@@ -0,0 +1,233 @@
+// Persistent state: read positions, catalog positions, history, the
+// watchlist and hidden items. Stored in a bbolt database (pure Go, no cgo) at
+// $XDG_STATE_HOME/ibb/state.db.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Buckets
+const (
+	readBucket      = "read"      // board/thread -> readState
+	catalogBucket   = "catalog"   // board -> OP number of selected thread
+	historyBucket   = "history"   // board/thread -> historyEntry
+	watchlistBucket = "watchlist" // board/thread -> watchEntry
+	sortBucket      = "sort"      // board -> sortMode
+	hiddenBucket    = "hidden"    // board/thread[/post] -> hiddenEntry
+)
+
+const historySize = 100
+
+// The database is only opened for the duration of each transaction, as bbolt
+// locks the file while it is open; this allows concurrent ibb instances to
+// share it.
+type stateStore struct {
+	path string
+}
+
+// $XDG_STATE_HOME/ibb
+func stateDir() string {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "ibb")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "ibb")
+	}
+	return filepath.Join(home, ".local", "state", "ibb")
+}
+
+var state = &stateStore{path: filepath.Join(stateDir(), "state.db")}
+
+func threadKeyString(board string, id int) string {
+	return fmt.Sprintf("%s/%d", board, id)
+}
+
+func (s *stateStore) open() (*bolt.DB, error) {
+	if err := os.MkdirAll(filepath.Dir(s.path), os.ModePerm); err != nil {
+		return nil, err
+	}
+	return bolt.Open(s.path, 0600, &bolt.Options{Timeout: time.Second})
+}
+
+func (s *stateStore) update(fn func(tx *bolt.Tx) error) error {
+	db, err := s.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.Update(fn)
+}
+
+func (s *stateStore) view(fn func(tx *bolt.Tx) error) error {
+	if _, err := os.Stat(s.path); err != nil {
+		return nil // nothing stored yet
+	}
+	db, err := s.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.View(fn)
+}
+
+// Read a JSON value into v. Returns false if not found (or on error, which is
+// logged; state is never important enough to crash over).
+func (s *stateStore) get(bucket string, key string, v any) (found bool) {
+	err := s.view(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		data := b.Get([]byte(key))
+		if data == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(data, v)
+	})
+	if err != nil {
+		log.Println("state:", bucket, key, err)
+		return false
+	}
+	return found
+}
+
+func (s *stateStore) put(bucket string, key string, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	err = s.update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key), data)
+	})
+	if err != nil {
+		log.Println("state:", bucket, key, err)
+	}
+}
+
+func (s *stateStore) delete(bucket string, keys ...string) {
+	err := s.update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		for _, key := range keys {
+			if err := b.Delete([]byte(key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Println("state:", bucket, keys, err)
+	}
+}
+
+// Call fn for every value in a bucket, in key order
+func (s *stateStore) each(bucket string, fn func(key string, data []byte)) {
+	err := s.view(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			fn(string(k), v)
+			return nil
+		})
+	})
+	if err != nil {
+		log.Println("state:", bucket, err)
+	}
+}
+
+type readState struct {
+	Read int `json:"read"` // number of last read post
+	Seen int `json:"seen"` // number of last post in thread, at last visit
+}
+
+func (s *stateStore) readState(board string, id int) (rs readState) {
+	s.get(readBucket, threadKeyString(board, id), &rs)
+	return rs
+}
+
+func (s *stateStore) setReadState(board string, id int, rs readState) {
+	s.put(readBucket, threadKeyString(board, id), rs)
+}
+
+// Returns OP number of the thread last selected in the catalog
+func (s *stateStore) catalogPosition(board string) (id int) {
+	s.get(catalogBucket, board, &id)
+	return id
+}
+
+func (s *stateStore) setCatalogPosition(board string, id int) {
+	s.put(catalogBucket, board, id)
+}
+
+type historyEntry struct {
+	Board   string    `json:"board"`
+	Thread  int       `json:"thread"`
+	Subject string    `json:"subject"`
+	Visited time.Time `json:"visited"`
+}
+
+func (e historyEntry) String() string {
+	return fmt.Sprintf(
+		"%s /%s/%d %s",
+		e.Visited.Format(time.DateTime),
+		e.Board,
+		e.Thread,
+		e.Subject,
+	)
+}
+
+// Record a visit to a thread, forgetting the oldest visits if there are too
+// many
+func (s *stateStore) visit(t *Thread) {
+	op := t.Posts[0]
+	subject := op.Subject
+	if subject == "" {
+		subject = op.lineComment()
+	}
+	s.put(historyBucket, threadKeyString(t.Board, op.Num), historyEntry{
+		Board:   t.Board,
+		Thread:  op.Num,
+		Subject: subject,
+		Visited: time.Now(),
+	})
+
+	if h := s.history(); len(h) > historySize {
+		var old []string
+		for _, e := range h[historySize:] {
+			old = append(old, threadKeyString(e.Board, e.Thread))
+		}
+		s.delete(historyBucket, old...)
+	}
+}
+
+// Returns recently visited threads, most recent first
+func (s *stateStore) history() (entries []historyEntry) {
+	s.each(historyBucket, func(_ string, data []byte) {
+		var e historyEntry
+		if json.Unmarshal(data, &e) == nil {
+			entries = append(entries, e)
+		}
+	})
+	slices.SortFunc(entries, func(a, b historyEntry) int { return b.Visited.Compare(a.Visited) })
+	return entries
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateStore(t *testing.T) {
+	s := &stateStore{path: filepath.Join(t.TempDir(), "state.db")}
+
+	// nothing stored yet
+	assert.Equal(t, readState{}, s.readState("g", 1))
+	assert.Equal(t, 0, s.catalogPosition("g"))
+	assert.Empty(t, s.history())
+
+	s.setReadState("g", 1, readState{Read: 5, Seen: 10})
+	assert.Equal(t, readState{Read: 5, Seen: 10}, s.readState("g", 1))
+
+	s.setCatalogPosition("g", 123)
+	assert.Equal(t, 123, s.catalogPosition("g"))
+
+	for i := range historySize + 5 {
+		s.visit(&Thread{Board: "g", Posts: []*Post{{Num: i + 1, Subject: "foo"}}})
+	}
+	h := s.history()
+	assert.Len(t, h, historySize)
+	assert.Equal(t, historySize+5, h[0].Thread)
+}
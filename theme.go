@@ -0,0 +1,134 @@
+// Themes: all colours used by the viewer
+
+package main
+
+import (
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+type theme struct {
+	border    lipgloss.TerminalColor
+	selected  lipgloss.Style // row under the cursor
+	header    lipgloss.Style
+	greentext lipgloss.Style
+	quote     lipgloss.Style // >>123
+	op        lipgloss.Style // (OP), after quotes of the OP
+	you       lipgloss.Style // (You)
+	unread    lipgloss.Style // posts new since last visit
+	match     lipgloss.Style // search matches
+	hidden    lipgloss.Style // filtered posts, when shown; unavailable actions
+	// Filter highlights use the colour of the rule, unless the theme has
+	// no colours
+	noColor bool
+}
+
+var themes = map[string]theme{
+	"default": {
+		border:    lipgloss.NoColor{},
+		selected:  lipgloss.NewStyle(),
+		header:    lipgloss.NewStyle(),
+		greentext: lipgloss.NewStyle().Foreground(lipgloss.Color("2")),
+		quote:     lipgloss.NewStyle().Foreground(lipgloss.Color("1")),
+		op:        lipgloss.NewStyle().Foreground(lipgloss.Color("4")),
+		you:       lipgloss.NewStyle().Foreground(lipgloss.Color("5")).Bold(true),
+		unread:    lipgloss.NewStyle().Bold(true),
+		match:     lipgloss.NewStyle().Foreground(lipgloss.Color("3")),
+		hidden:    lipgloss.NewStyle().Faint(true),
+	},
+	"solarized": {
+		border:    lipgloss.Color("#586e75"),
+		selected:  lipgloss.NewStyle().Foreground(lipgloss.Color("#268bd2")).Bold(true),
+		header:    lipgloss.NewStyle().Foreground(lipgloss.Color("#93a1a1")),
+		greentext: lipgloss.NewStyle().Foreground(lipgloss.Color("#859900")),
+		quote:     lipgloss.NewStyle().Foreground(lipgloss.Color("#dc322f")),
+		op:        lipgloss.NewStyle().Foreground(lipgloss.Color("#6c71c4")),
+		you:       lipgloss.NewStyle().Foreground(lipgloss.Color("#d33682")).Bold(true),
+		unread:    lipgloss.NewStyle().Foreground(lipgloss.Color("#cb4b16")).Bold(true),
+		match:     lipgloss.NewStyle().Foreground(lipgloss.Color("#b58900")),
+		hidden:    lipgloss.NewStyle().Foreground(lipgloss.Color("#586e75")),
+	},
+	"high-contrast": {
+		border:    lipgloss.Color("15"),
+		selected:  lipgloss.NewStyle().Reverse(true).Bold(true),
+		header:    lipgloss.NewStyle().Foreground(lipgloss.Color("15")).Bold(true),
+		greentext: lipgloss.NewStyle().Foreground(lipgloss.Color("10")).Bold(true),
+		quote:     lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Bold(true),
+		op:        lipgloss.NewStyle().Foreground(lipgloss.Color("14")).Bold(true),
+		you:       lipgloss.NewStyle().Foreground(lipgloss.Color("13")).Bold(true),
+		unread:    lipgloss.NewStyle().Foreground(lipgloss.Color("15")).Bold(true),
+		match:     lipgloss.NewStyle().Foreground(lipgloss.Color("11")).Bold(true),
+		hidden:    lipgloss.NewStyle().Foreground(lipgloss.Color("8")),
+	},
+	"monochrome": {
+		border:    lipgloss.NoColor{},
+		selected:  lipgloss.NewStyle().Reverse(true),
+		header:    lipgloss.NewStyle(),
+		greentext: lipgloss.NewStyle(),
+		quote:     lipgloss.NewStyle().Underline(true),
+		op:        lipgloss.NewStyle(),
+		you:       lipgloss.NewStyle().Bold(true),
+		unread:    lipgloss.NewStyle().Bold(true),
+		match:     lipgloss.NewStyle().Underline(true),
+		hidden:    lipgloss.NewStyle().Faint(true),
+		noColor:   true,
+	},
+}
+
+// Returns the named theme; monochrome if NO_COLOR is set (https://no-color.org)
+func getTheme(name string) (theme, bool) {
+	if os.Getenv("NO_COLOR") != "" {
+		name = "monochrome"
+	}
+	t, ok := themes[name]
+	return t, ok
+}
+
+func themeNames() (names []string) {
+	for name := range themes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Style of a filter highlight
+func (t theme) highlight(color string) lipgloss.Style {
+	if t.noColor {
+		return lipgloss.NewStyle().Underline(true)
+	}
+	return lipgloss.NewStyle().Foreground(lipgloss.Color(color))
+}
+
+var quoteLine = regexp.MustCompile(`^(\s*)>>(\d+)(.*)$`)
+
+// Style a rendered comment: greentext, and quotes (marked if they quote the
+// OP or (You))
+func (t theme) comment(text string, op int, isYou func(int) bool) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, "\t ")
+		indent := line[:len(line)-len(trimmed)]
+		switch {
+		case quoteLine.MatchString(line):
+			m := quoteLine.FindStringSubmatch(line)
+			id, _ := strconv.Atoi(m[2])
+			s := m[1] + t.quote.Render(">>"+m[2])
+			switch {
+			case id == op:
+				s += " " + t.op.Render("(OP)")
+			case isYou(id):
+				s += " " + t.you.Render("(You)")
+			}
+			lines[i] = s + m[3]
+		case strings.HasPrefix(trimmed, ">"):
+			lines[i] = indent + t.greentext.Render(trimmed)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
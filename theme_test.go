@@ -0,0 +1,25 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTheme(t *testing.T) {
+	cfg := defaultConfig()
+	assert.Error(t, decodeConfig(strings.NewReader("theme: nope"), &cfg))
+
+	t.Setenv("NO_COLOR", "1")
+	cfg = defaultConfig()
+	assert.NoError(t, decodeConfig(strings.NewReader("theme: solarized"), &cfg))
+	assert.True(t, cfg.styles.noColor)
+
+	// no tty in tests, so styles render as plain text
+	you := func(id int) bool { return id == 3 }
+	assert.Equal(t,
+		">>1 (OP)\n>>3 (You) lol\n\t>>2\n>implying",
+		themes["default"].comment(">>1\n>>3 lol\n\t>>2\n>implying", 1, you),
+	)
+}
@@ -5,7 +5,6 @@ import (
 	"log"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"slices"
 	"strconv"
 	"strings"
@@ -18,113 +17,168 @@ import (
 )
 
 type ThreadViewer struct {
-	thread      Thread // contains .Posts
-	cursor      int
-	moveCount   int  // vim-like navigation (e.g. 5j)
-	showComment bool // if false, show image (if available)
-	catalog     bool // generally only affects View
-
-	// TODO: ambiguous field names: thread / catalog
-
-	searching bool
-	input     string
-	matches   []int // updated via ThreadViewer.updateSearch
-
-	height int
-	width  int
-	short  bool
-
-	ticker    *time.Ticker // 10 min, only effective in thread
-	refreshed bool
+	*buffer             // current buffer
+	buffers   []*buffer // open catalogs, threads and search results, in tab order
+	moveCount int       // vim-like navigation (e.g. 5j); reset after each action
+	start     int       // post to open the first thread at, e.g. from a url
+
+	pending *action // waiting for the next key, which is its argument
+	arg     string  // key typed after a pending action
+	prefix  string  // keys typed so far of a key sequence, e.g. "g"
+
+	height  int
+	width   int
+	short   bool   // no room for images; list only, without border
+	side    bool   // image/comment pane right of the list, rather than below
+	arrange string // auto, stacked or side-by-side; see resize
+	split   int    // percent of the screen taken by the list
+
+	layout layout // as last drawn by View
+
+	panel       panel // if not noPanel, shown instead of posts
+	panelCursor int
+	helpMode    mode // where help was opened from
+
+	boards      []string        // board list; fetched when first shown
+	selected    map[string]bool // boards selected in the board list
+	boardQuery  string          // cross-board query typed in the board list
+	typingQuery bool            // typing boardQuery
+
+	status string // result of last action; cleared on next key
 }
 
-// Write to $HOME/subject/time.ext
-func (p Post) saveImage(subj string) error {
-	path, err := p.imagePath()
-	if err != nil {
-		panic(err)
-	}
-
-	home, err := os.UserHomeDir()
-	if err != nil {
-		panic(err)
-	}
-
-	// subj := m.thread.Posts[0].Subject
-	dest := filepath.Join(
-		home,
-		strings.ToLower(subj),
-		filepath.Base(path),
-	)
-
-	if strings.HasPrefix(path, home) {
-		if _, err := os.Stat(dest); err == nil {
-			return nil
-		}
-		log.Println(path, "->", dest)
-		return os.Link(path, dest)
-	}
-
-	b, err := os.ReadFile(path)
-	if err != nil {
-		panic(err)
+// Options of the tea.Program running the viewer
+func viewerOptions() []tea.ProgramOption {
+	opts := []tea.ProgramOption{tea.WithAltScreen()}
+	if config.Mouse {
+		opts = append(opts, tea.WithMouseCellMotion())
 	}
-	return os.WriteFile(dest, b, 0664)
+	return opts
 }
 
 // Render current image in a goroutine. Note that rendering is done entirely
-// outside the tea.Program (both visually and operationally).
-//
-// Because an area of the window must be allocated for the rendered image (in
-// our case, the bottom half of the available vertical space), the dimensions
-// of the ThreadViewer are therefore expected to be constrained.
+// outside the tea.Program (both visually and operationally); the image is
+// drawn over the pane left empty by View.
 func (m *ThreadViewer) display() {
+	if len(m.thread.Posts) == 0 {
+		return
+	}
 	post := m.currentPost()
+	area, _ := m.paneAreas(post)
+	if area == nil {
+		return
+	}
 
-	fname, err := post.imagePath()
+	fname, err := post.displayPath()
 	if err == nil {
 		// download should not be async here; otherwise img will only
 		// be rendered on 2nd load (async background dl is ok though)
-		post.download()
+		err = downloads.fetch(post)
 		log.Println("displaying:", fname, err)
 	}
+	if err == nil {
+		go Render(fname, area)
+	}
+}
 
-	hasImage := err == nil
-	hasComment := post.Comment != ""
+// Files at least this large show download progress in the header
+const progressThreshold = 1 << 20
 
-	sz := &Size{width: m.width, height: m.height}
+type progressMsg struct{}
 
-	// ensure that going from text post -> img post automatically displays
-	// the image
-	// TODO: but this also makes " " do nothing on img posts
-	m.showComment = !hasImage
+func tickProgress() tea.Cmd {
+	return tea.Tick(time.Second/5, func(time.Time) tea.Msg { return progressMsg{} })
+}
 
-	switch {
-	case m.height < 50: // don't render
-		// log.Println("too small")
+// Returns download progress of the current post's image, if it is large
+// enough to be worth showing
+func (m *ThreadViewer) downloading() (float64, bool) {
+	if len(m.thread.Posts) == 0 {
+		return 0, false
+	}
+	f, err := m.currentPost().displayFile()
+	if err != nil || f.size < progressThreshold {
+		return 0, false
+	}
+	return downloadProgressOf(f.url)
+}
 
-	case m.showComment && !hasComment:
-		m.showComment = false
-		go Render(fname, sz)
+// Whether the current post's image is large and not yet downloaded
+func (m *ThreadViewer) needsDownload() bool {
+	if len(m.thread.Posts) == 0 {
+		return false
+	}
+	f, err := m.currentPost().displayFile()
+	if err != nil || f.size < progressThreshold {
+		return false
+	}
+	_, err = os.Stat(f.path())
+	return err != nil
+}
 
-	case !m.showComment && hasImage:
-		go Render(fname, sz)
+// Recompute matches, and move to the first match after the position the
+// search was started from
+func (m *ThreadViewer) updateSearch() {
+	m.updateMatches()
+	m.cursor = m.searchFrom
+	m.nextMatch(0)
+	log.Println("input:", m.input, len(m.thread.Posts), "posts", len(m.matches), "matches")
+}
 
-	case m.showComment && hasComment: // body will be displayed in View
+// Recompute matches without moving the cursor, e.g. after a reload
+func (b *buffer) updateMatches() {
+	b.matches = nil
+	if b.input != "" {
+		b.matches = b.thread.filterPosts(parseQuery(b.input))
+	}
+}
 
-	case !m.showComment && !hasImage:
-		m.showComment = true
+func (m *ThreadViewer) clearSearch() {
+	m.matches = nil
+	m.input = ""
+}
 
-	default:
-		panic("unhandled condition")
-		// log.Println("unhandled", m.showComment, hasImage, hasComment)
+// Move to the nearest match after (dir > 0) or before (dir < 0) the cursor,
+// wrapping around. If dir is 0, the current post counts as a match.
+func (m *ThreadViewer) nextMatch(dir int) {
+	if len(m.matches) == 0 {
+		if dir != 0 {
+			m.status = "no matches"
+		}
+		return
+	}
+	i, _ := slices.BinarySearch(m.matches, m.cursor) // first match >= cursor
+	switch {
+	case dir < 0:
+		i--
+	case dir > 0 && i < len(m.matches) && m.matches[i] == m.cursor:
+		i++
 	}
+	m.cursor = m.matches[(i+len(m.matches))%len(m.matches)]
 }
 
-func (m *ThreadViewer) updateSearch() {
-	m.matches = m.thread.filterPosts(m.input)
-	m.cursor = 0
-	log.Println("input:", m.input, len(m.thread.Posts), "posts", len(m.matches), "matches")
+// Position of the current post among the matches (1-based), or 0
+func (m *ThreadViewer) matchIndex() int {
+	i, found := slices.BinarySearch(m.matches, m.cursor)
+	if !found {
+		return 0
+	}
+	return i + 1
+}
+
+// Download images of posts around the cursor in the background
+func (m *ThreadViewer) prefetch() {
+	posts := m.thread.Posts
+	var queue []*Post
+	// nearest first
+	for d := 1; d <= prefetchDist; d++ {
+		for _, i := range []int{m.cursor + d, m.cursor - d} {
+			if i >= 0 && i < len(posts) {
+				queue = append(queue, posts[i])
+			}
+		}
+	}
+	downloads.prefetch(queue)
 }
 
 func (m *ThreadViewer) move(n int) {
@@ -142,11 +196,17 @@ func (m *ThreadViewer) move(n int) {
 	m.moveCount = 0
 }
 
-func (m *ThreadViewer) currentPost() *Post {
-	if len(m.matches) > 0 { // get actual index via m.matches
-		return m.thread.Posts[m.matches[m.cursor]]
+func (b *buffer) currentPost() *Post {
+	return b.thread.Posts[b.cursor]
+}
+
+// First post of the thread the current post is in; in the catalog, the post
+// itself
+func (b *buffer) currentOP() *Post {
+	if b.catalog {
+		return b.currentPost()
 	}
-	return m.thread.Posts[m.cursor]
+	return b.thread.Posts[0]
 }
 
 // Init is the first function that will be called. It returns an optional
@@ -156,34 +216,198 @@ func (m *ThreadViewer) Init() tea.Cmd {
 	if err != nil {
 		panic(err)
 	}
-	m.width = w
-	m.height = h
-	m.short = m.height < 50
+	m.resize(w, h)
 
-	m.ticker = time.NewTicker(time.Minute * 10)
+	go sched.run()
 
-	go func() {
-		for {
-			// https://github.com/dominikh/go-tools/issues/503#issuecomment-497020529
-			t := <-m.ticker.C
-			if m.catalog {
-				continue
+	switch m.catalog {
+	case true:
+		m.thread.Posts = filterPosts(m.thread.Board, m.thread.Posts, true)
+		m.sort = state.sortMode(m.thread.Board)
+		m.sortCatalog()
+		if idx, err := m.thread.getIndex(state.catalogPosition(m.thread.Board)); err == nil {
+			m.cursor = idx
+		}
+	case false:
+		// start thread view at last post, unless it has been read
+		// before. note that this is only triggered on startup
+		t := m.thread
+		m.thread = Thread{} // nothing to leave
+		post := 0
+		if state.readState(t.Board, t.Posts[0].Num).Read == 0 {
+			post = t.newest()
+		}
+		if _, err := t.getIndex(m.start); m.start != 0 && err == nil {
+			post = m.start
+		}
+		m.openThread(&t, post)
+	}
+
+	// m.display() // doing this will render 1st image 2x on startup
+	return sched.listen()
+}
+
+// Switch to thread view, at the given post. If post is 0 (or not shown),
+// resume at the last read post (or the first post, if the thread has not been
+// read before).
+func (m *ThreadViewer) openThread(t *Thread, post int) {
+	m.leave()
+
+	op := t.Posts[0]
+	rs := state.readState(t.Board, op.Num)
+	state.visit(t)
+
+	m.thread = *t
+	m.thread.Posts = filterPosts(t.Board, t.Posts, false)
+	m.cursor = 0
+	if idx, err := m.thread.getIndex(post); err == nil {
+		m.cursor = idx
+	} else if idx, err := m.thread.getIndex(rs.Read); err == nil {
+		m.cursor = idx
+	}
+	m.lastRead = rs.Read
+	m.newSince = rs.Seen
+	m.unread = map[int]bool{}
+	if rs.Read > 0 {
+		for _, p := range m.thread.Posts {
+			if p.Num > rs.Read {
+				m.unread[p.Num] = true
 			}
-			m.thread.Posts = getThread(m.thread.Board, m.thread.Posts[0].Num).Posts
-			m.refreshed = true
-			log.Println("updated", t)
 		}
-	}()
+	}
+	m.catalog = false
+	m.marks = nil
+	m.jumps = nil
+	m.clearSearch()
+	m.panel = noPanel
+	m.followOpen()
+	m.markRead()
+	m.prefetch()
+}
 
-	// start thread view at last post. note that this is only triggered on
-	// startup, and not on state transitions
-	if !m.catalog {
-		m.cursor = len(m.thread.Posts) - 1
+// Switch to catalog view, with the cursor on the given thread (if it is still
+// in the catalog)
+func (m *ThreadViewer) openCatalog(c Catalog, id int) {
+	c.Posts = filterPosts(c.Board, c.Posts, true)
+
+	m.thread = Thread(c)
+	m.sort = state.sortMode(m.thread.Board)
+	m.sort.sort(m.thread.Posts)
+	m.cursor = 0
+	if idx, err := m.thread.getIndex(id); err == nil {
+		m.cursor = idx
 	}
+	m.catalog = true
+	m.clearSearch()
+	m.prefetch()
+}
 
-	_ = os.Mkdir(tmpDir, os.ModePerm)
-	// m.display() // doing this will render 1st image 2x on startup
-	return nil
+// Replace posts of the current catalog (or search results), keeping the
+// cursor on the selected post. Posts may be empty, if all threads are filtered.
+func (m *ThreadViewer) replacePosts(posts []*Post) {
+	var id int
+	if len(m.thread.Posts) > 0 {
+		id = m.currentPost().Num
+	}
+	m.thread.Posts = posts
+	if m.catalog {
+		m.sort.sort(m.thread.Posts)
+	}
+	m.cursor = max(0, min(m.cursor, len(posts)-1))
+	if idx, err := m.thread.getIndex(id); err == nil {
+		m.cursor = idx
+	}
+	m.updateMatches()
+}
+
+// Sort catalog threads, keeping the cursor on the selected thread
+func (m *ThreadViewer) sortCatalog() {
+	if len(m.thread.Posts) == 0 {
+		return
+	}
+	id := m.currentPost().Num
+	m.sort.sort(m.thread.Posts)
+	if idx, err := m.thread.getIndex(id); err == nil {
+		m.cursor = idx
+	}
+}
+
+// Persist position in the current catalog or thread, before leaving it
+func (b *buffer) leave() {
+	if len(b.thread.Posts) == 0 {
+		return
+	}
+	switch b.catalog {
+	case true:
+		if b.results == nil {
+			state.setCatalogPosition(b.thread.Board, b.currentPost().Num)
+		}
+	case false:
+		b.saveReadState()
+	}
+}
+
+// Replace posts of the current thread with freshly fetched ones, filtering
+// them and keeping the cursor on the selected post. Posts newer than any seen
+// before are marked unread; posts that were merely hidden before are not.
+func (b *buffer) refreshPosts(posts []*Post) {
+	posts = filterPosts(b.thread.Board, posts, false)
+	id := b.currentPost().Num
+	newest := max(b.thread.newest(), b.lastRead)
+	for _, p := range posts {
+		if p.Num > newest {
+			b.unread[p.Num] = true
+		}
+	}
+	b.thread.Posts = posts
+	b.cursor = min(b.cursor, len(posts)-1)
+	if idx, err := b.thread.getIndex(id); err == nil {
+		b.cursor = idx
+	}
+	b.updateMatches()
+	b.saveReadState()
+	watched.read(&b.thread, b.isUnread)
+}
+
+func (b *buffer) isUnread(p *Post) bool {
+	return b.unread[p.Num]
+}
+
+// Mark the current post as read
+func (m *ThreadViewer) markRead() {
+	curr := m.currentPost()
+	if !m.unread[curr.Num] && curr.Num <= m.lastRead {
+		return
+	}
+	delete(m.unread, curr.Num)
+	m.lastRead = max(m.lastRead, curr.Num)
+	watched.read(&m.thread, m.isUnread)
+}
+
+// Move to the next unread post, if any
+func (m *ThreadViewer) nextUnread() {
+	n := len(m.thread.Posts)
+	for i := 1; i < n; i++ {
+		idx := (m.cursor + i) % n
+		if m.unread[m.thread.Posts[idx].Num] {
+			m.cursor = idx
+			return
+		}
+	}
+	m.status = "no unread posts"
+}
+
+func (m *ThreadViewer) markAllRead() {
+	m.unread = map[int]bool{}
+	m.lastRead = m.thread.newest()
+	watched.read(&m.thread, m.isUnread)
+}
+
+func (b *buffer) saveReadState() {
+	state.setReadState(b.thread.Board, b.thread.Posts[0].Num, readState{
+		Read: b.lastRead,
+		Seen: b.thread.newest(),
+	})
 }
 
 func (m *ThreadViewer) updateScreen() tea.Cmd {
@@ -204,321 +428,597 @@ func (m *ThreadViewer) updateScreen() tea.Cmd {
 func (m *ThreadViewer) Update(msg tea.Msg) (_ tea.Model, cmd tea.Cmd) {
 	cmd = m.updateScreen()
 
-	pgDist := m.height / 2
-	if !m.short {
-		pgDist *= 2
-	}
-
 	// log.Println("msg", msg, spew.Sdump(msg))
 
 	switch msg := msg.(type) {
 
 	// case tea.ClearScreenMsg: // no longer exported
 
+	case dlDoneMsg:
+		cmd = nil
+		m.status = "download: " + msg.summary.String()
+
+	case progressMsg: // redraw header until download finishes
+		cmd = nil
+		if _, ok := m.downloading(); ok {
+			cmd = tickProgress()
+		}
+
+	case threadMsg:
+		cmd = sched.listen()
+		if b := m.findThread(msg.key); b != nil {
+			b.refreshPosts(msg.thread.Posts)
+			log.Println("updated", msg.key)
+		}
+
+	case boardSearchMsg:
+		switch {
+		case len(msg.search.posts) > 0:
+			m.showResults(msg.search)
+			if msg.err != nil {
+				m.status = "some boards failed; see log"
+			}
+		case msg.err != nil:
+			m.status = "search failed: " + msg.err.Error()
+			cmd = nil
+		default:
+			m.status = msg.search.String() + ": no matches"
+			cmd = nil
+		}
+
+	case watchlistMsg: // redraw
+		cmd = sched.listen()
+
 	case tea.WindowSizeMsg:
-		m.width = msg.Width
-		m.height = msg.Height
-		m.short = m.height < 50
+		m.resize(msg.Width, msg.Height)
 		if m.short {
 			return m, tea.ClearScreen
 		}
 		return m, m.updateScreen()
 
 	case tea.KeyMsg:
+		m.status = ""
+		return m, m.handleKey(msg)
 
-		m.refreshed = false
+	case tea.MouseMsg:
+		return m, m.handleMouse(msg)
 
-		s := msg.String()
+	default: // if not nil, will spam redraws!
+		cmd = nil
+	}
+	return m, cmd
+}
 
-		// enter must be checked -before- possible state transitions!
-		if m.searching {
-			switch s {
-			case "esc", "enter":
-				m.searching = false
-				return m, cmd
-			case "backspace":
-				if m.input == "" {
-					m.searching = false
-					break
-				}
-				m.input = m.input[:len(m.input)-1]
-				m.updateSearch()
-			default:
-				m.input += string(msg.Runes[0])
-				m.updateSearch()
-			}
-			return m, nil // do NOT redraw on search
+var (
+	blankEnum  = func(items list.Items, index int) string { return "" }
+	isSelected = map[bool]string{true: ">", false: " "}
+)
+
+// Run the action bound to a key
+// Actions available in a catalog whose threads are all filtered
+var withoutPosts = []string{
+	"next-buffer", "prev-buffer", "close-buffer", "toggle-filtered", "sort",
+	"reload", "split-grow", "split-shrink", "layout", "redraw", "watchlist",
+	"history", "hidden", "buffers", "boards", "help", "cancel", "quit",
+}
+
+func (m *ThreadViewer) handleKey(msg tea.KeyMsg) tea.Cmd {
+	s := msg.String()
+	md := m.mode()
+	a, ok := config.keymap.lookup(s, md)
+
+	if m.prefix != "" { // e.g. the t in gt
+		s = m.prefix + " " + s
+		m.prefix = ""
+		a, ok = config.keymap.lookup(s, md)
+	}
+
+	if m.pending != nil { // e.g. the a in ma
+		a, m.pending, m.arg = m.pending, nil, s
+		if s == "esc" {
+			m.moveCount = 0
+			return nil
 		}
+		return m.runAction(a)
+	}
 
-		// state transitions
-		if m.catalog && s == "enter" {
+	switch {
+	case md == searchMode && m.typingQuery:
+		return m.updateBoardQuery(msg, a, ok)
 
-			m.thread = *getThread(m.thread.Board, m.currentPost().Num)
-			m.cursor = 0 // TODO: could keep some kind of {thread_id: idx} history in a map/db
-			m.catalog = false
-			m.matches = nil
-			m.input = ""
-			return m, cmd
+	case md == searchMode:
+		return m.updateSearchInput(msg, a, ok)
 
-		} else if !m.catalog && s == "h" {
+	case config.keymap.isPrefix(s, md):
+		m.prefix = s
+		return nil
 
-			go m.thread.cleanImages()
-			id := m.thread.Posts[0].Num
-			c := getCatalog(m.thread.Board) // TODO: .asThread?
+	case md&listMode != 0 && ok:
+		return m.updatePanel(a.name)
 
-			m.thread = Thread(c)
-			catIdx, err := m.thread.getIndex(id)
-			if err != nil {
-				panic(err)
-			}
-			m.cursor = catIdx
-			m.catalog = true
-			m.matches = nil
-			m.input = ""
-			return m, cmd
+	case len(s) == 1 && s[0] >= '0' && s[0] <= '9' && !ok:
+		n, _ := strconv.Atoi(s)
+		m.moveCount = 10*m.moveCount + n
+		return nil
 
+	case a == nil:
+		log.Println("unhandled input:", s)
+		return nil
+
+	case !ok:
+		m.status = fmt.Sprintf("%s: not available in %s", a.name, md)
+		return nil
+
+	case len(m.thread.Posts) == 0 && !slices.Contains(withoutPosts, a.name):
+		m.status = "all threads filtered"
+		return nil
+	}
+
+	if a.arg {
+		m.pending = a
+		return nil
+	}
+	return m.runAction(a)
+}
+
+func (m *ThreadViewer) runAction(a *action) tea.Cmd {
+	var cmd tea.Cmd
+	if a.redraw {
+		cmd = m.updateScreen()
+	}
+	cmd = m.settle(cmd, a.run(m))
+	m.moveCount = 0
+	return cmd
+}
+
+// After the cursor may have moved: prefetch, mark read, and show download
+// progress
+func (m *ThreadViewer) settle(cmds ...tea.Cmd) tea.Cmd {
+	m.prefetch()
+	if !m.catalog {
+		m.markRead()
+	}
+	if m.needsDownload() {
+		cmds = append(cmds, tickProgress())
+	}
+	return tea.Batch(cmds...)
+}
+
+// Handle a key while typing a search query
+func (m *ThreadViewer) updateSearchInput(msg tea.KeyMsg, a *action, ok bool) tea.Cmd {
+	var name string
+	if ok {
+		name = a.name
+	}
+	switch {
+	case name == "search-accept":
+		m.searching = false
+		m.nextMatch(0)
+		return m.updateScreen()
+	case name == "search-cancel":
+		m.searching = false
+		m.cursor = m.searchFrom
+		m.clearSearch()
+		return m.updateScreen()
+	case msg.Type == tea.KeyBackspace:
+		if m.input == "" {
+			m.searching = false
+			break
 		}
+		r := []rune(m.input)
+		m.input = string(r[:len(r)-1])
+		m.updateSearch()
+	case len(msg.Runes) > 0:
+		m.input += string(msg.Runes)
+		m.updateSearch()
+	}
+	return nil // do NOT redraw on search
+}
 
-		switch s {
+func (m *ThreadViewer) pageDist() int {
+	if m.short {
+		return m.height / 2
+	}
+	return m.height
+}
 
-		case "q", "esc":
-			_ = os.RemoveAll(tmpDir)
-			cmd = tea.Quit
+func (m *ThreadViewer) top() tea.Cmd {
+	switch m.moveCount {
+	case 0:
+		m.cursor = 0
+	default:
+		m.cursor = min(m.moveCount, len(m.thread.Posts)) - 1
+		m.moveCount = 0
+	}
+	return nil
+}
 
-		case "1", "2", "3", "4", "5", "6", "7", "8", "9", "0":
-			cmd = nil
-			n, _ := strconv.Atoi(s)
-			m.moveCount = 10*m.moveCount + n
+func (m *ThreadViewer) open() tea.Cmd {
+	p := m.currentPost()
+	if b := m.findThread(threadKey{p.Board, p.Num}); b != nil {
+		m.switchTo(b)
+		return nil
+	}
+	t, err := fetchThread(p.Board, p.Num)
+	if err != nil {
+		m.status = err.Error()
+		return nil
+	}
+	m.insertBuffer(&buffer{from: m.buffer})
+	m.openThread(t, 0)
+	return nil
+}
 
-		case "/": // start search; catalog-only
-			cmd = nil
-			if m.catalog {
-				m.searching = true
-			}
+// From a thread, go back to the catalog (or the search results) it was opened
+// from; from search results, go to the catalog of the selected thread's
+// board. Catalogs still open are not fetched again.
+func (m *ThreadViewer) back() tea.Cmd {
+	switch {
+	case !m.catalog && slices.Contains(m.buffers, m.from):
+		id := m.thread.Posts[0].Num
+		m.switchTo(m.from)
+		if idx, err := m.thread.getIndex(id); err == nil {
+			m.cursor = idx
+		}
 
-		case "p": // play video urls (and webms); thread-only
-			cmd = nil
-			if !m.catalog {
-				go func() {
-					var args []string
-					// args := []string{"--force-window"}
-					for _, p := range m.thread.Posts {
-						for _, line := range p.htmlComment() {
-							if strings.Contains(line, "youtube.com/watch") {
-								args = append(args, line)
-							}
-						}
-					}
-					slices.Reverse(args)
-					args = append([]string{"--force-window"}, args...)
-					_ = exec.Command("mpv", args...).Run()
-				}()
+	case !m.catalog || m.results != nil:
+		p := m.currentPost()
+		if !m.catalog {
+			p = m.thread.Posts[0]
+		}
+		if b := m.findCatalog(p.Board); b != nil {
+			m.switchTo(b)
+			if idx, err := m.thread.getIndex(p.Num); err == nil {
+				m.cursor = idx
 			}
+			return nil
+		}
+		c, err := fetchCatalog(p.Board)
+		if err != nil {
+			m.status = err.Error()
+			return nil
+		}
+		go pruneCaches()
+		m.insertBuffer(&buffer{})
+		m.openCatalog(c, p.Num)
 
-		case "y": // copy current image url to clipboard
-			cmd = nil
-			url, err := m.currentPost().imageUrl()
-			if err != nil {
-				break
-			}
-			// https://github.com/rck/serve/blob/87b073e24bac82bd6f34434f2510b2a807d45982/main.go#L86
-			// echo -n foo | xclip -sel c
-			xclip := exec.Command("xclip", "-sel", "c", "-i")
-			in, _ := xclip.StdinPipe()
-			_ = xclip.Start()
-			_, _ = in.Write([]byte(url))
-			in.Close()
-			_ = xclip.Wait()
-
-		case "t": // toggle all posts / text posts only
-
-		case "r": // reload
-			switch m.catalog {
-			case true:
-				m.thread.Posts = getCatalog(m.thread.Board).Posts
-			case false:
-				m.thread.Posts = getThread(m.thread.Board, m.thread.Posts[0].Num).Posts
-			}
-			m.matches = nil
-			m.input = ""
+	default:
+		m.status = "already in catalog"
+	}
+	return nil
+}
 
-		case "s": // save image (copy, rather)
-			post := m.currentPost()
-			if err := post.saveImage(m.thread.Posts[0].Subject); err != nil {
-				panic(err)
-			}
-			m.move(1)
-
-		case "ctrl+l": // redraw (like tty)
-
-		case " ":
-			// toggle img<>text
-			// TODO: in short mode, this field is currently
-			// irrelevant and thus does nothing
-			m.showComment = !m.showComment
-
-		// movement
-
-		case "j":
-			m.move(1)
-		case "k":
-			m.move(-1)
-
-		case "pgdown":
-			m.move(pgDist)
-		case "pgup":
-			m.move(-pgDist)
-
-		case "g":
-			switch m.moveCount {
-			case 0:
-				m.cursor = 0
-			default:
-				m.cursor = m.moveCount - 1
-				m.moveCount = 0
+// Save image (copy, rather) and advance
+func (m *ThreadViewer) save() tea.Cmd {
+	dest, saved, err := m.currentPost().saveImage(m.currentOP())
+	switch {
+	case err != nil:
+		m.status = "save failed: " + err.Error()
+	case saved:
+		m.status = "saved " + dest
+		m.move(1)
+	default:
+		m.status = "exists " + dest
+		m.move(1)
+	}
+	return nil
+}
+
+// Copy current image url to clipboard
+func (m *ThreadViewer) yank() tea.Cmd {
+	url, err := m.currentPost().imageUrl()
+	if err != nil {
+		return nil
+	}
+	// https://github.com/rck/serve/blob/87b073e24bac82bd6f34434f2510b2a807d45982/main.go#L86
+	// echo -n foo | xclip -sel c
+	xclip := exec.Command("xclip", "-sel", "c", "-i")
+	in, _ := xclip.StdinPipe()
+	_ = xclip.Start()
+	_, _ = in.Write([]byte(url))
+	in.Close()
+	_ = xclip.Wait()
+	return nil
+}
+
+// Play video urls (and webms)
+func (m *ThreadViewer) play() tea.Cmd {
+	go func() {
+		var args []string
+		// args := []string{"--force-window"}
+		for _, p := range m.thread.Posts {
+			for _, line := range p.htmlComment() {
+				if strings.Contains(line, "youtube.com/watch") {
+					args = append(args, line)
+				}
 			}
+		}
+		slices.Reverse(args)
+		args = append([]string{"--force-window"}, args...)
+		_ = exec.Command("mpv", args...).Run()
+	}()
+	return nil
+}
 
-		case "G":
-			m.cursor = len(m.thread.Posts) - 1
-			m.moveCount = 0
+func (m *ThreadViewer) hide() tea.Cmd {
+	e := newHiddenEntry(&m.thread, m.currentPost(), m.catalog)
+	if !m.catalog && e.Post == 0 {
+		m.status = "can't hide OP; hide the thread from the catalog"
+		return nil
+	}
+	state.hide(e)
+	m.status = "hidden " + e.String()
+	return m.reload()
+}
 
-		default:
-			log.Println("unhandled input:", s)
+func (m *ThreadViewer) cycleSort() tea.Cmd {
+	m.sort = m.sort.next()
+	if m.results == nil {
+		state.setSortMode(m.thread.Board, m.sort)
+	}
+	m.sortCatalog()
+	m.updateMatches()
+	return nil
+}
 
+func (m *ThreadViewer) watch() tea.Cmd {
+	t := &m.thread
+	if m.catalog {
+		var err error
+		if t, err = fetchThread(m.currentPost().Board, m.currentPost().Num); err != nil {
+			m.status = err.Error()
+			return nil
 		}
+	}
+	if watched.toggle(t) {
+		m.status = "watching " + t.Posts[0].Subject
+	} else {
+		m.status = "unwatched " + t.Posts[0].Subject
+	}
+	return nil
+}
 
-	default: // if not nil, will spam redraws!
-		cmd = nil
+// Fetch the current catalog, thread or search results again, e.g. after
+// filters have changed
+func (m *ThreadViewer) reload() tea.Cmd {
+	switch {
+	case m.results != nil && m.catalog:
+		return m.searchBoards(m.results.query, m.results.boards)
+	case m.catalog:
+		c, err := fetchCatalog(m.thread.Board)
+		if err != nil {
+			m.status = err.Error()
+			return nil
+		}
+		m.replacePosts(filterPosts(m.thread.Board, c.Posts, true))
+	default:
+		t, err := fetchThread(m.thread.Board, m.thread.Posts[0].Num)
+		if err != nil {
+			m.status = err.Error()
+			return nil
+		}
+		m.refreshPosts(t.Posts)
 	}
-	return m, cmd
+	return nil
 }
 
-var (
-	blankEnum  = func(items list.Items, index int) string { return "" }
-	isSelected = map[bool]string{true: ">", false: " "}
-)
+func (m *ThreadViewer) quit() tea.Cmd {
+	for _, b := range m.buffers {
+		b.leave()
+	}
+	pruneCaches()
+	return tea.Quit
+}
 
 // View renders the program's UI, which is just a string. The view is
 // rendered after every Update.
 func (m *ThreadViewer) View() string {
-	var scrolloff int
-	switch m.short {
-	case true:
-		// scrolloff = (m.height - 3) / 2 // with border
-		scrolloff = (m.height - 1) / 2
-	case false:
-		scrolloff = m.height / 4
+	if m.panel != noPanel {
+		return m.viewPanel()
 	}
 
 	posts := m.thread.Posts
-	switch {
-	case m.input != "" && len(m.matches) == 0:
-		return "no matches"
-	case m.input != "" && len(m.matches) > 0:
-		var filtered []*Post
-		for _, match := range m.matches {
-			filtered = append(filtered, posts[match])
-		}
-		posts = filtered
+	if len(posts) == 0 {
+		return m.viewEmpty()
 	}
-
-	start, end := getScrollWindow(m.cursor, &posts, scrolloff)
+	start, end := m.scrollWindow()
 
 	postsList := list.New().Enumerator(blankEnum)
 
 	curr := posts[m.cursor]
 
-	// edge case: if odd height, include 1 less item (otherwise last item
-	// is oob)
-	if m.height%2 == 1 && m.cursor > scrolloff { // && m.short {
-		start += 1
-	}
-
 	// log.Println("view cursor at", m.cursor)
 	// log.Println("cursor", m.cursor, "/ model height", m.height, "/ posts", end-start)
 	// log.Println(m.cursor, curr.Subject, curr.Comment)
 
-	for _, p := range posts[start:end] {
+	var rows []string
+	var rowPosts []int // index of the post in each row; -1 for the divider
+	var cursorRow int
+	divider := false
+
+	for i, p := range posts[start:end] {
 		if p == nil { // window indices may exceed that of Posts
 			panic("oob!")
 		}
 
-		// TODO: relative line numbering
+		if m.isFirstNew(posts, start+i) {
+			rows = append(rows, "  --- new since last visit ---")
+			rowPosts = append(rowPosts, -1)
+			divider = true
+		}
+		if curr.Num == p.Num {
+			cursorRow = len(rows)
+		}
 
-		selected := isSelected[curr.Num == p.Num]
+		selected := m.lineNumber(start+i) + isSelected[curr.Num == p.Num]
 
 		var item string
 		switch {
 		// TODO: imgCount
+		case m.results != nil && m.catalog:
+			subject := p.Subject
+			if subject == "" {
+				subject = p.lineComment()
+			}
+			item = fmt.Sprintf("%s /%s/ %s", selected, p.Board, subject)
 		case m.catalog && p.Subject != "":
 			item = fmt.Sprintf("%s %s", selected, p.Subject)
+		case !m.catalog && m.isYou(p.Num):
+			item = fmt.Sprintf("%s (You) %s", selected, p.lineComment())
 		default:
 			item = fmt.Sprintf("%s %s", selected, p.lineComment())
 		}
 
 		// truncate (-5 is somewhat arbitrary)
 		// 6 chars of padding: border, space, cursor, space | space, border
-		if len(item) > m.width-5 {
-			item = item[:m.width-5]
+		if w := m.listWidth() - 5; len(item) > w {
+			item = item[:max(0, w)]
+		}
+		item = m.styleRow(item, p, start+i, curr.Num == p.Num)
+		rows = append(rows, item)
+		rowPosts = append(rowPosts, start+i)
+	}
+
+	// keep the number of rows constant, dropping the row furthest from
+	// the cursor
+	if divider {
+		switch {
+		case cursorRow < len(rows)/2:
+			rows = rows[:len(rows)-1]
+			rowPosts = rowPosts[:len(rowPosts)-1]
+		default:
+			rows = rows[1:]
+			rowPosts = rowPosts[1:]
 		}
-		postsList.Item(item)
+	}
+	for _, row := range rows {
+		postsList.Item(row)
 	}
 
 	header := m.header(curr.Num)
+	m.layout = layout{rows: rowPosts, commentTop: -1}
 
 	var panes string
 	switch m.short {
 	case true: // replace border with underline, don't show images
-		header = lipgloss.NewStyle().Underline(true).Render(header)
+		header = config.styles.header.Underline(true).Render(header)
 		panes = lipgloss.NewStyle().
 			MaxHeight(m.height - 1).
 			Width(m.width).
 			Render(postsList.String())
+		m.layout.listTop = 1
 
 	case false:
+		list := lipgloss.NewStyle().
+			Width(m.listWidth() - 3). // -1 for each border
+			Height(m.listRows()).
+			MaxHeight(m.listRows() + 2).
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(config.styles.border).
+			Render(postsList.String())
+		m.layout.listTop = 2 // below header and border
+
 		var body string
-		if m.showComment {
-			body = curr.QuoteComment(&m.thread)
-		}
-
-		panes = lipgloss.JoinVertical(
-			lipgloss.Left,
-			lipgloss.NewStyle().
-				Width(m.width-3). // -1 for each border
-				MaxHeight(m.height/2+2).
-				Border(lipgloss.RoundedBorder()).
-				Render(postsList.String()),
-			lipgloss.NewStyle().Width(m.width).Render(body),
-		)
+		if image, text := m.paneAreas(curr); text != nil {
+			body = config.styles.comment(curr.QuoteComment(&m.thread), m.thread.Posts[0].Num, m.isYou)
+			body = m.viewComment(curr.Num, body, text, image)
+		}
+
+		switch m.side {
+		case true:
+			m.layout.paneLeft = m.listWidth()
+			panes = lipgloss.JoinHorizontal(lipgloss.Top, list, " ", body)
+		case false:
+			panes = lipgloss.JoinVertical(lipgloss.Left, list, body)
+		}
 	}
 
 	return lipgloss.JoinVertical(lipgloss.Right, header, panes)
 }
 
+// View of a catalog whose threads are all filtered, like an empty panel
+func (m *ThreadViewer) viewEmpty() string {
+	m.layout = layout{listTop: 2, commentTop: -1}
+	return lipgloss.JoinVertical(
+		lipgloss.Left,
+		m.header(0),
+		lipgloss.NewStyle().
+			Width(m.width-3).
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(config.styles.border).
+			Render("  (all threads filtered)"),
+	)
+}
+
+// Style a (truncated) row of the posts list
+func (m *ThreadViewer) styleRow(item string, p *Post, i int, selected bool) string {
+	styles := config.styles
+	if !m.catalog && m.isYou(p.Num) {
+		item = strings.Replace(item, "(You)", styles.you.Render("(You)"), 1)
+	}
+	switch {
+	case p.filter.hidden:
+		item = styles.hidden.Render(item)
+	case p.filter.color != "":
+		item = styles.highlight(p.filter.color).Render(item)
+	}
+	if _, ok := slices.BinarySearch(m.matches, i); ok {
+		item = styles.match.Render(item)
+	}
+	if !m.catalog && m.unread[p.Num] {
+		item = styles.unread.Render(item)
+	}
+	if selected {
+		item = styles.selected.Render(item)
+	}
+	return item
+}
+
+// Whether a post in the current thread is marked as (You)
+func (m *ThreadViewer) isYou(num int) bool {
+	return watched.isYou(m.thread.Board, m.thread.Posts[0].Num, num)
+}
+
+// Whether posts[i] is the first post made since the last visit
+func (m *ThreadViewer) isFirstNew(posts []*Post, i int) bool {
+	return !m.catalog &&
+		m.newSince > 0 &&
+		i > 0 &&
+		posts[i].Num > m.newSince &&
+		posts[i-1].Num <= m.newSince
+}
+
 func (m *ThreadViewer) header(currId int) (header string) {
-	var total int
-	switch len(m.matches) {
-	case 0:
-		total = len(m.thread.Posts)
-	default:
-		total = len(m.matches)
+	header = fmt.Sprintf("[%d/%d] %d ", min(m.cursor+1, len(m.thread.Posts)), len(m.thread.Posts), currId)
+	if len(m.buffers) > 1 {
+		header = fmt.Sprintf("[tab %d/%d] %s", m.bufferIndex(), len(m.buffers), header)
 	}
-	header = fmt.Sprintf("[%d/%d] %d ", m.cursor+1, total, currId)
 
 	var title string
-	switch m.catalog {
-	case true:
-		title = m.thread.Board
+	switch {
+	case m.catalog && m.results != nil:
+		title = fmt.Sprintf("%s [sort: %s]", m.results, m.sort)
+		header = fmt.Sprintf(
+			"https://boards.4chan.org/%s/thread/%d %s ",
+			m.currentPost().Board,
+			currId,
+			header,
+		)
+
+	case m.catalog:
+		title = fmt.Sprintf("%s [sort: %s]", m.thread.Board, m.sort)
 		header = fmt.Sprintf("https://boards.4chan.org/%s %s ", m.thread.Board, header)
 
-	case false:
+	default:
 		title = m.thread.Posts[0].Subject
-		newPosts := len(m.thread.Posts) - 1 - m.cursor
-		if m.refreshed && newPosts > 0 {
-			// note: this is only valid if positioned at the last
-			// post. for better accuracy, newPosts should be calc'd
-			// at refresh time (i.e. in the goro)
-			title += fmt.Sprintf(" [%d new posts]", newPosts)
+		if watched.has(m.thread.Board, m.thread.Posts[0].Num) {
+			title += " [watched]"
+		}
+		if p, ok := m.downloading(); ok {
+			title += fmt.Sprintf(" [downloading %d%%]", int(p*100))
+		}
+		if n := len(m.unread); n > 0 {
+			title += fmt.Sprintf(" [%d new posts]", n)
 		}
 		header = fmt.Sprintf(
 			"https://boards.4chan.org/%s/thread/%d %s ",
@@ -529,19 +1029,29 @@ func (m *ThreadViewer) header(currId int) (header string) {
 
 	}
 
+	if showHidden.Load() {
+		title += " [showing filtered]"
+	}
+	if m.status != "" {
+		title = fmt.Sprintf("%s [%s]", title, m.status)
+	}
+
 	switch {
 	case m.searching && m.input == "":
-		title = fmt.Sprintf("%s [type to start searching]", title)
+		title = fmt.Sprintf("%s [type to search; prefixes: %s]", title, strings.Join(searchFields, ": ")+":")
 	case m.searching && m.input != "":
 		title = fmt.Sprintf("%s %s", title, m.input)
 	case !m.searching && m.input != "":
 		title = fmt.Sprintf("%s [%s]", title, m.input)
 	}
+	if m.input != "" {
+		title = fmt.Sprintf("%s [match %d/%d]", title, m.matchIndex(), len(m.matches))
+	}
 
 	header = lipgloss.JoinHorizontal(
 		lipgloss.Top,
-		lipgloss.NewStyle().PaddingRight(m.width-len(title)-len(header)).Render(" "+title),
-		lipgloss.NewStyle().Render(header),
+		config.styles.header.PaddingRight(m.width-len(title)-len(header)).Render(" "+title),
+		config.styles.header.Render(header),
 	)
 	return header
 }
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnread(t *testing.T) {
+	defer func(s *stateStore, wl *watchlist) { state, watched = s, wl }(state, watched)
+	state = &stateStore{path: filepath.Join(t.TempDir(), "state.db")}
+	watched = loadWatchlist(state)
+
+	cfg := defaultConfig()
+	require.NoError(t, decodeConfig(strings.NewReader(""), &cfg))
+	press := func(m *ThreadViewer, key string) {
+		a, ok := cfg.keymap.lookup(key, threadMode)
+		require.True(t, ok, key)
+		m.runAction(a)
+	}
+
+	// read up to the last post, 104
+	m := testViewer(5)
+	m.thread.Board = "g"
+	m.lastRead, m.newSince, m.unread = 104, 104, map[int]bool{}
+	m.cursor = 2
+
+	posts := m.thread.Posts
+	for i := range 3 {
+		posts = append(posts, &Post{Num: 105 + i})
+	}
+	m.refreshPosts(posts)
+	assert.Equal(t, map[int]bool{105: true, 106: true, 107: true}, m.unread)
+	assert.Equal(t, readState{Read: 104, Seen: 107}, state.readState("g", 100))
+
+	// divider above the first new post
+	for i := range m.thread.Posts {
+		assert.Equal(t, i == 5, m.isFirstNew(m.thread.Posts, i), i)
+	}
+	m.View()
+	assert.Equal(t, []int{0, 1, 2, 3, 4, -1, 5, 6}, m.layout.rows)
+
+	press(m, "u")
+	assert.Equal(t, 5, m.cursor)
+	assert.Equal(t, map[int]bool{106: true, 107: true}, m.unread)
+	press(m, "G")
+	press(m, "u") // wraps around
+	assert.Equal(t, 6, m.cursor)
+	assert.Empty(t, m.unread)
+	press(m, "u")
+	assert.Equal(t, 6, m.cursor)
+	assert.Equal(t, "no unread posts", m.status)
+
+	posts = m.thread.Posts
+	for i := range 2 {
+		posts = append(posts, &Post{Num: 108 + i})
+	}
+	m.refreshPosts(posts)
+	assert.Len(t, m.unread, 2)
+	press(m, "U")
+	assert.Empty(t, m.unread)
+	assert.Equal(t, 6, m.cursor)
+	m.leave()
+	assert.Equal(t, readState{Read: 109, Seen: 109}, state.readState("g", 100))
+
+	// hidden posts shown again are not new
+	defer showHidden.Store(false)
+	posts = m.thread.Posts
+	state.hide(hiddenEntry{Board: "g", Thread: 100, Post: 102})
+	m.refreshPosts(posts)
+	assert.Len(t, m.thread.Posts, 9)
+	showHidden.Store(true)
+	m.refreshPosts(posts)
+	assert.Len(t, m.thread.Posts, 10)
+	assert.Empty(t, m.unread)
+}
+
+func TestAllFiltered(t *testing.T) {
+	defer func(c Config, s *stateStore) { config, state = c, s }(config, state)
+	state = &stateStore{path: filepath.Join(t.TempDir(), "state.db")}
+	require.NoError(t, decodeConfig(strings.NewReader(`
+filters:
+  - field: name
+    pattern: .
+    action: hide
+`), &config))
+
+	m := testViewer(3)
+	m.catalog, m.thread.Board = true, "g"
+	for _, p := range m.thread.Posts {
+		p.Name = "Anonymous"
+	}
+	m.cursor = 2
+	m.replacePosts(filterPosts("g", m.thread.Posts, true))
+	assert.Empty(t, m.thread.Posts)
+	assert.Equal(t, 0, m.cursor)
+
+	m.sortCatalog()
+	assert.Contains(t, m.View(), "(all threads filtered)")
+	m.handleKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("j")})
+	assert.Equal(t, "all threads filtered", m.status)
+}
@@ -0,0 +1,156 @@
+// Headless thread watcher: save new images until the thread dies
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// Poll intervals, as recommended by the API docs: start at 10 s, back off
+// while nothing happens, reset once new posts appear
+var backoff = []time.Duration{
+	10 * time.Second,
+	15 * time.Second,
+	20 * time.Second,
+	30 * time.Second,
+	60 * time.Second,
+	90 * time.Second,
+	120 * time.Second,
+	180 * time.Second,
+	300 * time.Second,
+}
+
+type watchSummary struct {
+	polls    int
+	newPosts int
+	dlSummary
+}
+
+// Usage: ibb watch [flags] <board> <thread|url>
+func watchCmd(args []string) {
+	opts := defaultDlOptions
+
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: ibb watch [flags] <board> <thread|url>")
+		fs.PrintDefaults()
+	}
+	fs.IntVar(&opts.workers, "j", opts.workers, "number of concurrent downloads")
+
+	pos := parseInterspersed(fs, args)
+	if len(pos) != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	board := pos[0]
+	id, err := parseThreadArg(pos[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	sum, err := watch(ctx, board, id, opts)
+	fmt.Printf(
+		"%s after %d polls: %d new posts; %s\n",
+		err, sum.polls, sum.newPosts, sum.dlSummary,
+	)
+	if sum.failed > 0 {
+		os.Exit(1)
+	}
+}
+
+var (
+	errThreadArchived = errors.New("thread archived")
+	errThreadDead     = errors.New("thread 404'd")
+)
+
+// Poll a thread and save every new image, until the thread 404s, is
+// archived, or ctx is cancelled. The returned error says which. Images that
+// failed to save are retried on the next poll, and only count as failed if
+// they still have not been saved once the watch ends.
+func watch(ctx context.Context, board string, id int, opts dlOptions) (sum watchSummary, err error) {
+	var seen int // newest post seen
+	var done int // newest post up to which all images were saved
+	failing := map[int]bool{}
+	defer func() { sum.failed = len(failing) }()
+	step := 0
+
+	for {
+		var t *Thread
+		t, err = fetchThread(board, id)
+		sum.polls++
+		switch {
+		case errors.Is(err, errNotFound):
+			return sum, errThreadDead
+		case err != nil: // probably transient
+			fmt.Fprintln(os.Stderr, err)
+			step = min(step+1, len(backoff)-1)
+		default:
+			n, newest := 0, seen
+			for _, p := range t.Posts {
+				if p.Num > seen {
+					n++
+				}
+				newest = max(newest, p.Num)
+			}
+
+			if seen == 0 {
+				fmt.Printf("%s watching /%s/%d (%d posts)\n", timestamp(), board, id, len(t.Posts))
+			} else if n > 0 {
+				fmt.Printf("%s %d new posts (%d total)\n", timestamp(), n, len(t.Posts))
+				sum.newPosts += n
+			}
+			seen = newest
+
+			switch {
+			case n > 0:
+				step = 0
+			default:
+				step = min(step+1, len(backoff)-1)
+			}
+
+			// new posts, or images that failed last time
+			if done < newest {
+				opts.sincePost = done + 1
+				failed := newest + 1 // oldest post whose image failed
+				dl := downloadThread(t, opts, func(p *Post, dest string, err error) {
+					if err != nil {
+						fmt.Fprintln(os.Stderr, p.Num, err)
+						failed = min(failed, p.Num)
+						failing[p.Num] = true
+						return
+					}
+					delete(failing, p.Num)
+					fmt.Println(dest)
+				})
+				dl.failed = 0 // see failing
+				sum.add(dl)
+				done = failed - 1
+			}
+
+			if t.Posts[0].Archived == 1 {
+				return sum, errThreadArchived
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return sum, errors.New("interrupted")
+		case <-time.After(backoff[step]):
+		}
+	}
+}
+
+func timestamp() string {
+	return time.Now().Format(time.TimeOnly)
+}
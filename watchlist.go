@@ -0,0 +1,314 @@
+// Watched threads, and the scheduler that refreshes them in the background
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type watchEntry struct {
+	Board   string `json:"board"`
+	Thread  int    `json:"thread"`
+	Subject string `json:"subject"`
+	You     []int  `json:"you"` // post numbers marked as (You)
+
+	// updated on refresh
+	Posts    int  `json:"posts"`
+	Unread   int  `json:"unread"`
+	Replies  int  `json:"replies"` // unread replies to (You)
+	Dead     bool `json:"dead"`
+	Archived bool `json:"archived"`
+}
+
+// Update counts from a freshly fetched thread
+func (e *watchEntry) update(t *Thread, unread func(p *Post) bool) {
+	e.Posts = len(t.Posts)
+	e.Unread = 0
+	e.Replies = 0
+	e.Archived = t.Posts[0].Archived == 1
+	if e.Subject == "" {
+		e.Subject = t.Posts[0].Subject
+	}
+	for _, p := range t.Posts {
+		if !unread(p) {
+			continue
+		}
+		e.Unread++
+		if slices.ContainsFunc(p.quotes(), func(id int) bool { return slices.Contains(e.You, id) }) {
+			e.Replies++
+		}
+	}
+}
+
+// Posts after the last read post are unread
+func readUpTo(lastRead int) func(p *Post) bool {
+	return func(p *Post) bool { return p.Num > lastRead }
+}
+
+func (e watchEntry) String() string {
+	s := fmt.Sprintf("/%s/%d %s", e.Board, e.Thread, e.Subject)
+	switch {
+	case e.Dead:
+		s += " [dead]"
+	case e.Archived:
+		s += " [archived]"
+	}
+	if e.Unread > 0 {
+		s += fmt.Sprintf(" [%d unread]", e.Unread)
+	}
+	if e.Replies > 0 {
+		s += fmt.Sprintf(" [%d (You)]", e.Replies)
+	}
+	return s
+}
+
+// List of watched threads, persisted in the state store. Safe for concurrent
+// use.
+type watchlist struct {
+	mu      sync.Mutex
+	store   *stateStore
+	entries []*watchEntry
+}
+
+var watched = loadWatchlist(state)
+
+func loadWatchlist(store *stateStore) *watchlist {
+	wl := &watchlist{store: store}
+	store.each(watchlistBucket, func(_ string, data []byte) {
+		var e watchEntry
+		if err := json.Unmarshal(data, &e); err != nil {
+			log.Println("invalid watchlist entry:", err)
+			return
+		}
+		wl.entries = append(wl.entries, &e)
+	})
+	return wl
+}
+
+func (wl *watchlist) save(e *watchEntry) {
+	wl.store.put(watchlistBucket, threadKeyString(e.Board, e.Thread), e)
+}
+
+// Must be called with mu held
+func (wl *watchlist) find(board string, id int) *watchEntry {
+	for _, e := range wl.entries {
+		if e.Board == board && e.Thread == id {
+			return e
+		}
+	}
+	return nil
+}
+
+func (wl *watchlist) has(board string, id int) bool {
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+	return wl.find(board, id) != nil
+}
+
+// Returns a snapshot of all entries
+func (wl *watchlist) list() []watchEntry {
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+	entries := make([]watchEntry, len(wl.entries))
+	for i, e := range wl.entries {
+		entries[i] = *e
+	}
+	return entries
+}
+
+// Add thread if not watched, otherwise remove it. Returns whether the thread
+// is now watched.
+func (wl *watchlist) toggle(t *Thread) bool {
+	op := t.Posts[0]
+	if wl.has(t.Board, op.Num) {
+		wl.remove(t.Board, op.Num)
+		return false
+	}
+
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+	e := &watchEntry{Board: t.Board, Thread: op.Num, Subject: op.Subject}
+	e.update(t, readUpTo(wl.store.readState(t.Board, op.Num).Read))
+	wl.entries = append(wl.entries, e)
+	wl.save(e)
+	return true
+}
+
+func (wl *watchlist) remove(board string, id int) {
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+	wl.entries = slices.DeleteFunc(wl.entries, func(e *watchEntry) bool {
+		return e.Board == board && e.Thread == id
+	})
+	wl.store.delete(watchlistBucket, threadKeyString(board, id))
+}
+
+// Toggle a post as (You). The thread is watched if it wasn't already, since
+// replies to (You) are only tracked for watched threads. Returns whether the
+// post is now marked.
+func (wl *watchlist) toggleYou(t *Thread, num int) bool {
+	if !wl.has(t.Board, t.Posts[0].Num) {
+		wl.toggle(t)
+	}
+
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+
+	e := wl.find(t.Board, t.Posts[0].Num)
+	defer wl.save(e)
+	defer e.update(t, readUpTo(wl.store.readState(t.Board, e.Thread).Read))
+
+	if i := slices.Index(e.You, num); i >= 0 {
+		e.You = slices.Delete(e.You, i, i+1)
+		return false
+	}
+	e.You = append(e.You, num)
+	return true
+}
+
+func (wl *watchlist) isYou(board string, thread int, num int) bool {
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+	e := wl.find(board, thread)
+	return e != nil && slices.Contains(e.You, num)
+}
+
+// Update unread counts of a watched thread after reading it. Counts are only
+// persisted on the next refresh.
+func (wl *watchlist) read(t *Thread, unread func(p *Post) bool) {
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+	if e := wl.find(t.Board, t.Posts[0].Num); e != nil {
+		e.update(t, unread)
+	}
+}
+
+type threadKey struct {
+	board string
+	id    int
+}
+
+const (
+	watchInterval  = 2 * time.Minute  // watched threads
+	threadInterval = 10 * time.Minute // thread open in viewer
+)
+
+// Sent when the thread open in the viewer has been refreshed
+type threadMsg struct {
+	key    threadKey
+	thread *Thread
+}
+
+// Sent when watched threads have been refreshed
+type watchlistMsg struct{}
+
+// Refreshes threads in the background: all watched threads, and the threads
+// open in the viewer. Requests are made one at a time, so that many watched
+// threads do not starve the viewer of its rate limit.
+type scheduler struct {
+	mu        sync.Mutex
+	open      []threadKey // threads open in the viewer
+	refreshed map[threadKey]time.Time
+
+	msgs chan tea.Msg
+}
+
+var sched = &scheduler{
+	refreshed: map[threadKey]time.Time{},
+	msgs:      make(chan tea.Msg, 16),
+}
+
+// Set the threads open in the viewer
+func (s *scheduler) follow(keys ...threadKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, k := range keys {
+		if !slices.Contains(s.open, k) { // the viewer has just fetched it
+			s.refreshed[k] = time.Now()
+		}
+	}
+	s.open = keys
+}
+
+// Returns a command that waits for the next message from the scheduler.
+// Should be re-issued after every message received.
+func (s *scheduler) listen() tea.Cmd {
+	return func() tea.Msg { return <-s.msgs }
+}
+
+func (s *scheduler) send(msg tea.Msg) {
+	select {
+	case s.msgs <- msg:
+	default: // viewer not listening
+	}
+}
+
+// Returns threads due for refresh
+func (s *scheduler) due() (keys []threadKey, open []threadKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, e := range watched.list() {
+		k := threadKey{e.Board, e.Thread}
+		if !e.Dead && now.Sub(s.refreshed[k]) > watchInterval {
+			keys = append(keys, k)
+		}
+	}
+	for _, k := range s.open {
+		if now.Sub(s.refreshed[k]) > threadInterval && !slices.Contains(keys, k) {
+			keys = append(keys, k)
+		}
+	}
+	return keys, s.open
+}
+
+func (s *scheduler) run() {
+	for range time.Tick(10 * time.Second) {
+		keys, open := s.due()
+		if len(keys) == 0 {
+			continue
+		}
+
+		for _, k := range keys {
+			t, err := fetchThread(k.board, k.id)
+
+			s.mu.Lock()
+			s.refreshed[k] = time.Now()
+			s.mu.Unlock()
+
+			watched.refreshed(k, t, err)
+			if err == nil && slices.Contains(open, k) {
+				s.send(threadMsg{key: k, thread: t})
+			}
+			log.Println("refreshed", k, err)
+		}
+		s.send(watchlistMsg{})
+	}
+}
+
+// Update entry after a refresh
+func (wl *watchlist) refreshed(k threadKey, t *Thread, err error) {
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+	e := wl.find(k.board, k.id)
+	switch {
+	case e == nil:
+		return
+	case errors.Is(err, errNotFound):
+		e.Dead = true
+	case err != nil:
+		return
+	default:
+		e.update(t, readUpTo(wl.store.readState(k.board, k.id).Read))
+	}
+	wl.save(e)
+}
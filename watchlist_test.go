@@ -0,0 +1,41 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchlist(t *testing.T) {
+	thread := &Thread{Board: "g", Posts: []*Post{
+		{Num: 1, Subject: "foo"},
+		{Num: 2, Comment: "hi"},
+		{Num: 3, Comment: `<a href="#p2" class="quotelink">&gt;&gt;2</a><br>hello`},
+		{Num: 4, Comment: `&gt;&gt;1`},
+	}}
+	assert.Equal(t, []int{2}, thread.Posts[2].quotes())
+
+	store := &stateStore{path: filepath.Join(t.TempDir(), "state.db")}
+	store.setReadState("g", 1, readState{Read: 2})
+
+	wl := loadWatchlist(store)
+	assert.True(t, wl.toggle(thread))
+	assert.True(t, wl.toggleYou(thread, 2))
+
+	e := wl.list()[0]
+	assert.Equal(t, 2, e.Unread)
+	assert.Equal(t, 1, e.Replies)
+	assert.Equal(t, "/g/1 foo [2 unread] [1 (You)]", e.String())
+
+	wl.read(thread, readUpTo(3))
+	assert.Equal(t, 1, wl.list()[0].Unread)
+
+	// persisted
+	wl = loadWatchlist(store)
+	assert.True(t, wl.isYou("g", 1, 2))
+
+	assert.False(t, wl.toggle(thread))
+	assert.Empty(t, wl.list())
+	assert.Empty(t, loadWatchlist(store).list())
+}